@@ -0,0 +1,690 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+// options holds the flags that can be combined with any mode.
+type options struct {
+	theme       string
+	seed        int64
+	hasSeed     bool
+	source      string
+	sourceFile  string
+	filter      WordFilter
+	strictness  Strictness
+	fail        FailRules
+	matching    Matching
+	punctuation bool
+	numbers     bool
+	layout      string
+	osLayout    string
+	keyboard    bool
+	deadKeys    bool
+	display     DisplayOptions
+	json        bool
+
+	// N-gram drill settings
+	ngrams      string
+	top         int
+	setSize     int
+	reps        int
+	minAccuracy float64
+	minWPM      float64
+}
+
+// flagGroup is a set of related flags, registered together and listed
+// under one heading in help.
+type flagGroup struct {
+	title string
+	add   func(fs *flag.FlagSet, o *options)
+}
+
+// command is a term-type subcommand.
+type command struct {
+	name    string
+	aliases []string
+	args    string // positional arguments, for help
+	summary string
+	flags   []flagGroup
+
+	// check validates the positional arguments
+	check func(args []string) error
+
+	// usesConfig is set for commands that read the config file
+	usesConfig bool
+}
+
+// invocation is a parsed command line.
+type invocation struct {
+	cmd   *command
+	args  []string // the command's positional arguments
+	opts  options
+	flags []flagSetting // the flags given, in order
+}
+
+// flagSetting is one flag given on the command line.
+type flagSetting struct {
+	name, value string
+}
+
+// recordedValue wraps a flag's value to note each time it's set, so the
+// flags can be applied again over changed settings.
+type recordedValue struct {
+	flag.Value
+	name string
+	set  *[]flagSetting
+}
+
+func (v recordedValue) Set(s string) error {
+	if err := v.Value.Set(s); err != nil {
+		return err
+	}
+	*v.set = append(*v.set, flagSetting{v.name, s})
+	return nil
+}
+
+func (v recordedValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// flagChoices are the values flags taking a name accept, also offered by
+// shell completion.
+var flagChoices = map[string][]string{
+	"theme":      themeOrder,
+	"source":     sourceNames,
+	"language":   languages,
+	"stop-on":    stopOnModes,
+	"confidence": confidenceModes,
+	"lang":       matchLanguages,
+	"layout":     layoutOrder,
+	"os-layout":  layoutOrder,
+	"caret":      caretStyles,
+}
+
+var (
+	themeFlags = flagGroup{"Options", func(fs *flag.FlagSet, o *options) {
+		choiceVar(fs, &o.theme, "theme", "Set color `theme` (auto-detects Omarchy theme by default)")
+	}}
+	seedFlags = flagGroup{"Text options", func(fs *flag.FlagSet, o *options) {
+		fs.Func("seed", "Generate the same text every time for seed `N`", func(s string) error {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return errors.New("must be a whole number")
+			}
+			o.seed, o.hasSeed = n, true
+			return nil
+		})
+	}}
+	textFlags = flagGroup{"Text options", func(fs *flag.FlagSet, o *options) {
+		choiceVar(fs, &o.source, "source", "Word `source`: words (default) or markov")
+		fs.StringVar(&o.sourceFile, "source-file", o.sourceFile, "Train the markov source on your own text at `PATH` (implies --source markov)")
+		choiceVar(fs, &o.filter.Language, "language", "Word list `NAME` (default english)")
+		fs.BoolVar(&o.punctuation, "punctuation", o.punctuation, "Add capitals and punctuation to generated words")
+		fs.BoolVar(&o.numbers, "numbers", o.numbers, "Mix numbers in with generated words")
+	}}
+	strictnessFlags = flagGroup{"Strictness", func(fs *flag.FlagSet, o *options) {
+		choiceVar(fs, &o.strictness.StopOn, "stop-on", "Stop on `MODE`: letter (wrong keys don't move the cursor) or word (can't leave a word until it's correct)")
+		choiceVar(fs, &o.strictness.Confidence, "confidence", "Backspace limit `MODE`: on (can't go back into finished words) or max (disabled)")
+	}}
+	failFlags = flagGroup{"Fail rules (the test ends early and is marked failed)", func(fs *flag.FlagSet, o *options) {
+		fs.BoolVar(&o.fail.SuddenDeath, "sudden-death", o.fail.SuddenDeath, "Fail on the first mistake")
+		percentVar(fs, &o.fail.MinAccuracy, "fail-accuracy", "Fail if accuracy drops below `X`%")
+		numberVar(fs, &o.fail.MinWPM, "fail-wpm", "Fail if speed drops below `X` wpm")
+		fs.Func("fail-wpm-after", "Wait `N` seconds before --fail-wpm applies (default 5)", func(s string) error {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return errors.New("must be a number of at least 0")
+			}
+			o.fail.MinWPMAfter = n
+			return nil
+		})
+	}}
+	matchingFlags = flagGroup{"Matching (for languages your keyboard can't easily type)", func(fs *flag.FlagSet, o *options) {
+		fs.BoolVar(&o.matching.IgnoreAccents, "ignore-accents", o.matching.IgnoreAccents, "Accept letters without their accents: e for é, ss for ß")
+		choiceVar(fs, &o.matching.Lang, "lang", "Use language `CODE`'s own spellings with --ignore-accents, e.g. ae for ä in de")
+		fs.BoolVar(&o.matching.IgnoreCase, "ignore-case", o.matching.IgnoreCase, "Accept letters in either case")
+	}}
+	displayFlags = flagGroup{"Display", func(fs *flag.FlagSet, o *options) {
+		fs.BoolVar(&o.display.ShowTyped, "show-typed", o.display.ShowTyped, "Show the letters you typed for mistakes (toggle with Ctrl+T)")
+		fs.BoolVar(&o.display.Blind, "blind", o.display.Blind, "Don't show mistakes until the results")
+		positiveIntVar(fs, &o.display.Memory, "memory", "Hide the text you haven't typed yet `N` seconds after you start")
+		fs.BoolVar(&o.display.Tape, "tape", o.display.Tape, "Show the text on one line that scrolls as you type")
+		positiveIntVar(fs, &o.display.Lines, "lines", "Show at most `N` lines of wrapped text")
+		choiceVar(fs, &o.display.Caret, "caret", "Cursor `style`: underline (default), block, bar or none")
+		fs.BoolVar(&o.display.CaretBlink, "caret-blink", o.display.CaretBlink, "Make the cursor blink")
+		fs.BoolVar(&o.display.HighlightWord, "highlight-word", o.display.HighlightWord, "Shade the word you're typing")
+		fs.BoolVar(&o.display.Sound, "sound", o.display.Sound, "Ring the terminal bell on mistakes (not with --blind)")
+	}}
+	filterFlags = flagGroup{"Word filters (words source only)", func(fs *flag.FlagSet, o *options) {
+		positiveIntVar(fs, &o.filter.MinLen, "min-length", "Only words with at least `N` letters")
+		positiveIntVar(fs, &o.filter.MaxLen, "max-length", "Only words with at most `N` letters")
+		positiveIntVar(fs, &o.filter.Top, "top-words", "Only the `N` most common words")
+		fs.StringVar(&o.filter.Only, "only-letters", o.filter.Only, "Only words made entirely of these `letters`")
+		fs.StringVar(&o.filter.Exclude, "exclude-letters", o.filter.Exclude, "Skip words containing any of these `letters`")
+		fs.StringVar(&o.filter.Require, "require-letters", o.filter.Require, "Only words containing at least one of these `letters`")
+		fs.StringVar(&o.filter.Keys, "keys", o.filter.Keys, "Only words typeable with these key `groups` on --layout, e.g. home+top")
+	}}
+	layoutFlags = flagGroup{"Keyboard layouts", func(fs *flag.FlagSet, o *options) {
+		choiceVar(fs, &o.layout, "layout", "Layout `NAME` you're practicing; keys typed on --os-layout are translated to it")
+		choiceVar(fs, &o.osLayout, "os-layout", "Layout `NAME` your system is set to (default qwerty)")
+		fs.BoolVar(&o.keyboard, "keyboard", o.keyboard, "Show the on-screen keyboard (always on with --layout)")
+		fs.BoolVar(&o.deadKeys, "dead-keys", o.deadKeys, "Put accents your terminal sends for dead keys, like ^ or ´, on the next letter")
+	}}
+	outputFlags = flagGroup{"Output", func(fs *flag.FlagSet, o *options) {
+		fs.BoolVar(&o.json, "json", o.json, "Print the result as JSON when the test ends, and exit 1 if it's quit early")
+	}}
+	drillFlags = flagGroup{"Drill options", func(fs *flag.FlagSet, o *options) {
+		positiveIntVar(fs, &o.top, "top", "Drill the `N` most common (or slowest) n-grams (default 12)")
+		positiveIntVar(fs, &o.setSize, "set-size", "Practice `N` n-grams together in one test (default 3)")
+		positiveIntVar(fs, &o.reps, "reps", "Repeat each set `N` times in one test (default 4)")
+		percentVar(fs, &o.minAccuracy, "min-accuracy", "Accuracy `X` needed to move to the next set (default 95)")
+		numberVar(fs, &o.minWPM, "min-wpm", "Speed `X` in wpm needed to move to the next set (default 40)")
+	}}
+)
+
+// wordsFlags are the flags for tests of generated words.
+var wordsFlags = []flagGroup{themeFlags, seedFlags, textFlags, strictnessFlags, failFlags, matchingFlags, displayFlags, filterFlags, layoutFlags}
+
+// testFlags are the flags for starting a test from the command line.
+var testFlags = append(slices.Clone(wordsFlags), outputFlags)
+
+var commands = []*command{
+	{
+		name:       "menu",
+		summary:    "Open the interactive menu (the default)",
+		flags:      append(slices.Clone(wordsFlags), drillFlags),
+		usesConfig: true,
+	},
+	{
+		name: "time", aliases: []string{"t"}, args: "SECONDS",
+		summary:    "Timed test",
+		flags:      testFlags,
+		check:      countArg("time", "a positive number of seconds"),
+		usesConfig: true,
+	},
+	{
+		name: "words", aliases: []string{"w"}, args: "COUNT",
+		summary:    "Type COUNT words",
+		flags:      testFlags,
+		check:      countArg("words", "a positive number"),
+		usesConfig: true,
+	},
+	{
+		name: "quote", aliases: []string{"q"},
+		summary:    "Type a random quote",
+		flags:      []flagGroup{themeFlags, seedFlags, strictnessFlags, failFlags, matchingFlags, displayFlags, layoutFlags, outputFlags},
+		usesConfig: true,
+	},
+	{
+		name: "zen", aliases: []string{"z"},
+		summary:    "Type freely with no text to copy; ctrl+d finishes",
+		flags:      []flagGroup{themeFlags, displayFlags, layoutFlags, outputFlags},
+		usesConfig: true,
+	},
+	{
+		name: "ngrams", aliases: []string{"n"}, args: "[bigrams|trigrams|slowest]",
+		summary: "N-gram drill: common bigrams (default) or trigrams, or your slowest bigrams",
+		flags:   []flagGroup{themeFlags, strictnessFlags, failFlags, matchingFlags, displayFlags, layoutFlags, drillFlags, outputFlags},
+		check: func(args []string) error {
+			if len(args) > 1 || len(args) == 1 && !slices.Contains([]string{"bigrams", "trigrams", "slowest"}, args[0]) {
+				return errors.New("ngrams takes bigrams, trigrams or slowest")
+			}
+			return nil
+		},
+		usesConfig: true,
+	},
+	{
+		name: "history", aliases: []string{"h"},
+		summary:    "Show past results",
+		flags:      []flagGroup{themeFlags},
+		usesConfig: true,
+	},
+	{
+		name: "clear", args: "history|theme",
+		summary: "Clear history, or reset the theme to default",
+		check: func(args []string) error {
+			if len(args) != 1 || args[0] != "history" && args[0] != "theme" {
+				return errors.New("clear takes history or theme")
+			}
+			return nil
+		},
+	},
+	{
+		name:    "themes",
+		summary: "List available themes",
+	},
+	{
+		name: "completion", args: "bash|zsh|fish",
+		summary: "Print a shell completion script",
+		check: func(args []string) error {
+			if len(args) != 1 || !slices.Contains([]string{"bash", "zsh", "fish"}, args[0]) {
+				return errors.New("completion takes bash, zsh or fish")
+			}
+			return nil
+		},
+	},
+	{
+		name:    "version",
+		summary: "Print the version",
+	},
+	{
+		name: "help", args: "[COMMAND]",
+		summary: "Show help for term-type or a command",
+		check: func(args []string) error {
+			if len(args) > 1 {
+				return errors.New("help takes one command")
+			}
+			return nil
+		},
+	},
+}
+
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name || slices.Contains(c.aliases, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// countArg checks for the single positive number time and words take.
+func countArg(name, what string) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("%s takes %s", name, what)
+		}
+		if n, err := strconv.Atoi(args[0]); err != nil || n <= 0 {
+			return fmt.Errorf("%s must be %s", name, what)
+		}
+		return nil
+	}
+}
+
+// choiceVar registers a flag that takes one of flagChoices[name].
+func choiceVar(fs *flag.FlagSet, p *string, name, usage string) {
+	fs.Func(name, usage, func(s string) error {
+		if !slices.Contains(flagChoices[name], s) {
+			return fmt.Errorf("choose from %s", strings.Join(flagChoices[name], ", "))
+		}
+		*p = s
+		return nil
+	})
+}
+
+// positiveIntVar registers a flag that takes a count.
+func positiveIntVar(fs *flag.FlagSet, p *int, name, usage string) {
+	fs.Func(name, usage, func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return errors.New("must be a positive number")
+		}
+		*p = n
+		return nil
+	})
+}
+
+// numberVar registers a flag that takes a non-negative amount.
+func numberVar(fs *flag.FlagSet, p *float64, name, usage string) {
+	fs.Func(name, usage, func(s string) error {
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil || n < 0 {
+			return errors.New("must be a number of at least 0")
+		}
+		*p = n
+		return nil
+	})
+}
+
+// percentVar registers a flag that takes a percentage.
+func percentVar(fs *flag.FlagSet, p *float64, name, usage string) {
+	fs.Func(name, usage, func(s string) error {
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil || n < 0 || n > 100 {
+			return errors.New("must be a percentage from 0 to 100")
+		}
+		*p = n
+		return nil
+	})
+}
+
+// flagSet returns a flag set with cmd's flags, writing them into o.
+func (cmd *command) flagSet(o *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	for _, g := range cmd.flags {
+		g.add(fs, o)
+	}
+	return fs
+}
+
+// parseArgs reads the command line, starting from the settings in conf.
+// Flags can go before or after the command and its arguments, and the
+// command defaults to conf.Mode, then the menu. If help was asked for it
+// returns flag.ErrHelp along with the command it's for, which is nil for
+// term-type itself.
+func parseArgs(args []string, conf Config) (invocation, error) {
+	var inv invocation
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		if a == "--version" || a == "-version" {
+			inv.cmd = findCommand("version")
+			return inv, nil
+		}
+	}
+
+	// The command is the first argument that isn't a flag or a flag's
+	// value; without one the config's mode is used
+	i := commandIndex(args)
+	explicit := i >= 0
+	if explicit {
+		inv.cmd = findCommand(args[i])
+		if inv.cmd == nil {
+			return inv, fmt.Errorf("unknown command %q", args[i])
+		}
+		args = slices.Delete(slices.Clone(args), i, i+1)
+	} else if mode := strings.Fields(conf.Mode); len(mode) > 0 {
+		inv.cmd = findCommand(mode[0])
+		inv.args = mode[1:]
+	} else {
+		inv.cmd = findCommand("menu")
+	}
+
+	// Flags are checked and recorded here, and applied over conf by
+	// withConfig
+	var scratch options
+	fs := inv.cmd.flagSet(&scratch)
+	fs.VisitAll(func(f *flag.Flag) {
+		f.Value = recordedValue{f.Value, f.Name, &inv.flags}
+	})
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) && !explicit {
+				inv.cmd = nil
+			}
+			return inv, flagError(err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		inv.args = append(inv.args, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if inv.cmd.check != nil {
+		if err := inv.cmd.check(inv.args); err != nil {
+			return inv, err
+		}
+	} else if len(inv.args) > 0 {
+		return inv, fmt.Errorf("%s doesn't take arguments", inv.cmd.name)
+	}
+	if inv.cmd.name == "help" && len(inv.args) == 1 && findCommand(inv.args[0]) == nil {
+		return inv, fmt.Errorf("no help for %q: not a command", inv.args[0])
+	}
+
+	var err error
+	inv.opts, err = inv.withConfig(conf)
+	return inv, err
+}
+
+// withConfig returns the options for the invocation over the settings in
+// conf: the config's values, with the command line's flags applied again
+// on top. It's used again when the settings change while term-type runs.
+func (inv invocation) withConfig(conf Config) (options, error) {
+	var opts options
+	opts.theme = conf.Theme
+	opts.source = conf.Source
+	opts.filter.Language = conf.Language
+	opts.punctuation = conf.Punctuation
+	opts.numbers = conf.Numbers
+	opts.layout = conf.Layout
+	opts.osLayout = conf.OSLayout
+	opts.keyboard = conf.Keyboard
+	opts.deadKeys = conf.DeadKeys
+	opts.strictness = conf.Strictness
+	opts.fail = conf.Fail
+	opts.matching = conf.Matching
+	opts.display = conf.DisplayOptions
+
+	opts.ngrams = "bigrams"
+	opts.top = 12
+	opts.setSize = 3
+	opts.reps = 4
+	opts.minAccuracy = 95
+	opts.minWPM = 40
+	if opts.fail.MinWPMAfter == 0 {
+		opts.fail.MinWPMAfter = defaultMinWPMAfter
+	}
+
+	fs := inv.cmd.flagSet(&opts)
+	for _, f := range inv.flags {
+		if err := fs.Set(f.name, f.value); err != nil {
+			return opts, fmt.Errorf("--%s: %v", f.name, err)
+		}
+	}
+	if inv.cmd.name == "ngrams" && len(inv.args) == 1 {
+		opts.ngrams = inv.args[0]
+	}
+
+	if opts.filter.Keys != "" {
+		opts.filter.Layout = opts.layout
+	}
+	// The config's settings and the flags are checked together, since
+	// either can complete a combination that doesn't work
+	for _, v := range []interface{ Validate() error }{opts.filter, opts.strictness, opts.matching, opts.fail} {
+		if err := v.Validate(); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// usesConfig reports whether the command line runs something that reads
+// the config file. Help, version and the commands that only print
+// something don't, so a broken config or a stray variable can't stop them.
+func usesConfig(args []string) bool {
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		switch a {
+		case "-h", "-help", "--help", "-version", "--version":
+			return false
+		}
+	}
+	i := commandIndex(args)
+	if i < 0 {
+		return true
+	}
+	cmd := findCommand(args[i])
+	return cmd != nil && cmd.usesConfig
+}
+
+// commandIndex returns the position of the command in args, or -1.
+func commandIndex(args []string) int {
+	var all options
+	fs := findCommand("menu").flagSet(&all)
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			if i+1 < len(args) {
+				return i + 1
+			}
+			return -1
+		}
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			return i
+		}
+		name := strings.TrimLeft(a, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if f := fs.Lookup(name); f != nil && !isBoolFlag(f) {
+			i++
+		}
+	}
+	return -1
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// flagError rewrites the flag package's errors to name flags the way
+// they're documented, with two dashes.
+func flagError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	msg := err.Error()
+	msg = strings.Replace(msg, ": -", ": --", 1)
+	msg = strings.Replace(msg, "flag -", "flag --", 1)
+	return errors.New(msg)
+}
+
+// runCommand runs the commands that print something and exit, reporting
+// false for the ones that open the TUI.
+func runCommand(inv invocation, w io.Writer) (bool, error) {
+	switch inv.cmd.name {
+	case "help":
+		var cmd *command
+		if len(inv.args) == 1 {
+			cmd = findCommand(inv.args[0])
+		}
+		printUsage(w, cmd)
+	case "version":
+		fmt.Fprintf(w, "term-type %s\n", versionString())
+	case "completion":
+		return true, writeCompletion(w, inv.args[0])
+	case "themes":
+		fmt.Fprintln(w, "Available themes:")
+		for _, name := range themeOrder {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+		if detected := detectOmarchyTheme(); detected != "" {
+			fmt.Fprintf(w, "\nCurrent Omarchy theme: %s\n", detected)
+		}
+	case "clear":
+		if inv.args[0] == "history" {
+			if err := clearHistory(); err != nil {
+				return true, fmt.Errorf("clearing history: %v", err)
+			}
+			fmt.Fprintln(w, "History cleared.")
+		} else {
+			if err := clearThemePreference(); err != nil {
+				return true, fmt.Errorf("clearing theme: %v", err)
+			}
+			fmt.Fprintln(w, "Theme reset to default.")
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// versionString reports version, or the module version for builds made
+// with go install.
+func versionString() string {
+	if version == "dev" {
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			return info.Main.Version
+		}
+	}
+	return version
+}
+
+// printUsage writes help for cmd, or for term-type as a whole when cmd is
+// nil.
+func printUsage(w io.Writer, cmd *command) {
+	if cmd != nil && cmd.name != "menu" {
+		usage := "term-type " + cmd.name
+		if cmd.args != "" {
+			usage += " " + cmd.args
+		}
+		if len(cmd.flags) > 0 {
+			usage += " [flags]"
+		}
+		fmt.Fprintf(w, "Usage: %s\n\n%s.\n", usage, cmd.summary)
+		if len(cmd.aliases) > 0 {
+			fmt.Fprintf(w, "Alias: %s\n", strings.Join(cmd.aliases, ", "))
+		}
+		printFlags(w, cmd)
+		return
+	}
+
+	fmt.Fprint(w, "Usage: term-type [command] [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		name := c.name
+		if c.args != "" {
+			name += " " + c.args
+		}
+		fmt.Fprintf(w, "  %-34s %s\n", name, c.summary)
+	}
+	fmt.Fprint(w, `
+Flags go before or after the command, as --flag value or --flag=value.
+Run 'term-type COMMAND --help' to see the flags a command takes.
+`)
+	printFlags(w, findCommand("menu"))
+	fmt.Fprint(w, `
+Config:
+  Settings are read from ~/.config/term-type/config.json (edit it from
+  Settings on the menu). TERM_TYPE_<SETTING> environment variables override
+  it, e.g. TERM_TYPE_THEME=nord, and flags override both.
+
+Piped input:
+  echo "custom text" | term-type
+  cat quote.txt | term-type
+
+Examples:
+  term-type
+  term-type time 30 --theme catppuccin
+  term-type words 25 --seed 42
+  term-type --sudden-death words 50
+  term-type time 30 --language german --punctuation
+  term-type quote
+  term-type zen --json
+  term-type ngrams trigrams --min-wpm=50
+  term-type --layout colemak --keys home+top time 60
+  term-type completion bash > ~/.local/share/bash-completion/completions/term-type
+`)
+}
+
+// printFlags lists cmd's flags under their group headings.
+func printFlags(w io.Writer, cmd *command) {
+	seen := map[string]bool{}
+	for _, g := range cmd.flags {
+		var o options
+		fs := flag.NewFlagSet(g.title, flag.ContinueOnError)
+		g.add(fs, &o)
+		if !seen[g.title] {
+			fmt.Fprintf(w, "\n%s:\n", g.title)
+			seen[g.title] = true
+		}
+		fs.VisitAll(func(f *flag.Flag) {
+			name, usage := flag.UnquoteUsage(f)
+			spec := "--" + f.Name
+			if name != "" {
+				spec += " " + strings.ToUpper(name)
+			}
+			if len(spec) > 22 {
+				fmt.Fprintf(w, "  %s\n  %-22s %s\n", spec, "", usage)
+			} else {
+				fmt.Fprintf(w, "  %-22s %s\n", spec, usage)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		conf    Config
+		cmd     string
+		cmdArgs []string
+		check   func(o options) bool
+	}{
+		{name: "no arguments open the menu", cmd: "menu"},
+		{
+			name: "flags before the command",
+			args: []string{"--seed", "42", "time", "30"},
+			cmd:  "time", cmdArgs: []string{"30"},
+			check: func(o options) bool { return o.hasSeed && o.seed == 42 },
+		},
+		{
+			name: "flags after the command",
+			args: []string{"time", "30", "--seed", "42"},
+			cmd:  "time", cmdArgs: []string{"30"},
+			check: func(o options) bool { return o.hasSeed && o.seed == 42 },
+		},
+		{
+			name: "flags between the command and its argument",
+			args: []string{"words", "--punctuation", "25"},
+			cmd:  "words", cmdArgs: []string{"25"},
+			check: func(o options) bool { return o.punctuation },
+		},
+		{name: "alias", args: []string{"t", "15"}, cmd: "time", cmdArgs: []string{"15"}},
+		{name: "mode from the config", conf: Config{Mode: "words 50"}, cmd: "words", cmdArgs: []string{"50"}},
+		{name: "command beats the config's mode", args: []string{"time", "30"}, conf: Config{Mode: "quote"}, cmd: "time", cmdArgs: []string{"30"}},
+		{name: "double dash before the command", args: []string{"--", "time", "30"}, cmd: "time", cmdArgs: []string{"30"}},
+		{
+			name: "flag=value",
+			args: []string{"--theme=nord", "time", "30"},
+			cmd:  "time", cmdArgs: []string{"30"},
+			check: func(o options) bool { return o.theme == "nord" },
+		},
+		{
+			name:  "config value kept",
+			conf:  Config{Theme: "nord", Strictness: Strictness{StopOn: "letter"}},
+			cmd:   "menu",
+			check: func(o options) bool { return o.theme == "nord" && o.strictness.StopOn == "letter" },
+		},
+		{
+			name:  "flag overrides the config",
+			args:  []string{"--theme", "everforest"},
+			conf:  Config{Theme: "nord"},
+			cmd:   "menu",
+			check: func(o options) bool { return o.theme == "everforest" },
+		},
+		{
+			name: "=false overrides the config",
+			args: []string{"--punctuation=false", "--blind=false", "time", "30"},
+			conf: Config{Punctuation: true, DisplayOptions: DisplayOptions{Blind: true}},
+			cmd:  "time", cmdArgs: []string{"30"},
+			check: func(o options) bool { return !o.punctuation && !o.display.Blind },
+		},
+		{
+			name: "flag value isn't taken for the command",
+			args: []string{"--language", "german", "words", "10"},
+			cmd:  "words", cmdArgs: []string{"10"},
+			check: func(o options) bool { return o.filter.Language == "german" },
+		},
+		{
+			name: "flag value named like a command",
+			args: []string{"--source-file", "time", "words", "10"},
+			cmd:  "words", cmdArgs: []string{"10"},
+			check: func(o options) bool { return o.sourceFile == "time" },
+		},
+		{
+			name: "bool flag doesn't take the next word",
+			args: []string{"--punctuation", "words", "10"},
+			cmd:  "words", cmdArgs: []string{"10"},
+			check: func(o options) bool { return o.punctuation },
+		},
+		{
+			name: "ngrams argument",
+			args: []string{"ngrams", "trigrams"},
+			cmd:  "ngrams", cmdArgs: []string{"trigrams"},
+			check: func(o options) bool { return o.ngrams == "trigrams" },
+		},
+		{
+			name: "keys use the layout",
+			args: []string{"--layout", "colemak", "--keys", "home", "time", "30"},
+			cmd:  "time", cmdArgs: []string{"30"},
+			check: func(o options) bool { return o.filter.Layout == "colemak" },
+		},
+		{name: "version anywhere", args: []string{"time", "--version"}, cmd: "version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := parseArgs(tt.args, tt.conf)
+			if err != nil {
+				t.Fatalf("parseArgs(%q): %v", tt.args, err)
+			}
+			if inv.cmd == nil || inv.cmd.name != tt.cmd {
+				t.Fatalf("parseArgs(%q) command = %v, want %s", tt.args, inv.cmd, tt.cmd)
+			}
+			if len(inv.args) > 0 || len(tt.cmdArgs) > 0 {
+				if !slices.Equal(inv.args, tt.cmdArgs) {
+					t.Errorf("parseArgs(%q) args = %q, want %q", tt.args, inv.args, tt.cmdArgs)
+				}
+			}
+			if tt.check != nil && !tt.check(inv.opts) {
+				t.Errorf("parseArgs(%q) options = %+v", tt.args, inv.opts)
+			}
+		})
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		conf Config
+		want string // part of the error
+	}{
+		{name: "stop on word with confidence max", args: []string{"--stop-on", "word", "--confidence", "max", "time", "30"}, want: "confidence max"},
+		{name: "config stop on with flag confidence", args: []string{"--confidence", "max", "time", "30"}, conf: Config{Strictness: Strictness{StopOn: "word"}}, want: "confidence max"},
+		{name: "lang without ignore accents", args: []string{"--lang", "de", "time", "30"}, want: "ignoring accents"},
+		{name: "fail accuracy over 100", args: []string{"--fail-accuracy", "150", "time", "30"}, want: "--fail-accuracy"},
+		{name: "min length over max", args: []string{"--min-length", "6", "--max-length", "3", "time", "30"}, want: "greater than the maximum"},
+		{name: "unknown choice", args: []string{"--theme", "nope"}, want: "choose from"},
+		{name: "missing count", args: []string{"time"}, want: "time takes"},
+		{name: "bad count", args: []string{"words", "ten"}, want: "words must be"},
+		{name: "unknown command", args: []string{"bogus"}, want: `unknown command "bogus"`},
+		{name: "flag the command doesn't take", args: []string{"quote", "--punctuation"}, want: "--punctuation"},
+		{name: "unexpected argument", args: []string{"history", "all"}, want: "doesn't take arguments"},
+		{name: "help for an unknown command", args: []string{"help", "bogus"}, want: "not a command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseArgs(tt.args, tt.conf)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseArgs(%q) error = %v, want one containing %q", tt.args, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgsHelp(t *testing.T) {
+	inv, err := parseArgs([]string{"--help"}, Config{Mode: "time 30"})
+	if !errors.Is(err, flag.ErrHelp) || inv.cmd != nil {
+		t.Errorf("--help = %v, %v; want ErrHelp for term-type itself", inv.cmd, err)
+	}
+	for _, cmd := range commands {
+		t.Run(cmd.name, func(t *testing.T) {
+			inv, err := parseArgs([]string{cmd.name, "--help"}, Config{})
+			if !errors.Is(err, flag.ErrHelp) || inv.cmd != cmd {
+				t.Fatalf("%s --help = %v, %v; want ErrHelp for %s", cmd.name, inv.cmd, err, cmd.name)
+			}
+			var b bytes.Buffer
+			printUsage(&b, inv.cmd)
+			if !strings.Contains(b.String(), "term-type") {
+				t.Errorf("help for %s is missing usage:\n%s", cmd.name, b.String())
+			}
+		})
+	}
+}
+
+func TestWithConfig(t *testing.T) {
+	inv, err := parseArgs([]string{"--confidence", "max", "--blind=false", "time", "30"}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The flags still win over settings changed later
+	o, err := inv.withConfig(Config{Theme: "nord", DisplayOptions: DisplayOptions{Blind: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.theme != "nord" || o.strictness.Confidence != "max" || o.display.Blind {
+		t.Errorf("withConfig options = %+v", o)
+	}
+
+	// and are checked together with them
+	if _, err := inv.withConfig(Config{Strictness: Strictness{StopOn: "word"}}); err == nil {
+		t.Error("withConfig allowed stop on word under --confidence max")
+	}
+}
+
+func TestCommandIndex(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{nil, -1},
+		{[]string{"time", "30"}, 0},
+		{[]string{"--seed", "42", "time"}, 2},
+		{[]string{"--seed=42", "time"}, 1},
+		{[]string{"--punctuation", "time"}, 1},
+		{[]string{"--source-file", "words", "time"}, 2},
+		{[]string{"--theme", "nord"}, -1},
+		{[]string{"--", "time"}, 1},
+		{[]string{"--"}, -1},
+	}
+	for _, tt := range tests {
+		if got := commandIndex(tt.args); got != tt.want {
+			t.Errorf("commandIndex(%q) = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestUsesConfig(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"time", "30"}, true},
+		{[]string{"--theme", "nord", "history"}, true},
+		{[]string{"--help"}, false},
+		{[]string{"time", "--help"}, false},
+		{[]string{"version"}, false},
+		{[]string{"completion", "bash"}, false},
+		{[]string{"clear", "history"}, false},
+		{[]string{"bogus"}, false},
+	}
+	for _, tt := range tests {
+		if got := usesConfig(tt.args); got != tt.want {
+			t.Errorf("usesConfig(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var b bytes.Buffer
+		if err := writeCompletion(&b, shell); err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		for _, cmd := range commands {
+			if !strings.Contains(b.String(), cmd.name) {
+				t.Errorf("%s completion is missing %s", shell, cmd.name)
+			}
+		}
+		if !strings.Contains(b.String(), "dead-keys") {
+			t.Errorf("%s completion is missing --dead-keys", shell)
+		}
+	}
+	if err := writeCompletion(&bytes.Buffer{}, "tcsh"); err == nil {
+		t.Error("writeCompletion accepted tcsh")
+	}
+}
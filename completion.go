@@ -0,0 +1,221 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// completionFlag is a flag as shell completion sees it.
+type completionFlag struct {
+	name    string
+	usage   string
+	value   bool     // takes a value
+	choices []string // values to offer, if known
+}
+
+// completionFlags lists cmd's flags.
+func completionFlags(cmd *command) []completionFlag {
+	var o options
+	var flags []completionFlag
+	cmd.flagSet(&o).VisitAll(func(f *flag.Flag) {
+		_, usage := flag.UnquoteUsage(f)
+		flags = append(flags, completionFlag{
+			name:    f.Name,
+			usage:   usage,
+			value:   !isBoolFlag(f),
+			choices: flagChoices[f.Name],
+		})
+	})
+	return flags
+}
+
+// commandNames returns cmd's name and aliases.
+func commandNames(cmd *command) []string {
+	return append([]string{cmd.name}, cmd.aliases...)
+}
+
+// commandChoices are the words a command's arguments complete to.
+var commandChoices = map[string][]string{
+	"ngrams":     {"bigrams", "trigrams", "slowest"},
+	"clear":      {"history", "theme"},
+	"completion": {"bash", "zsh", "fish"},
+}
+
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		writeZshCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	default:
+		return fmt.Errorf("no completion for %q (choose from bash, zsh, fish)", shell)
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer) {
+	var names, valueFlags []string
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	// The menu takes every flag
+	allFlags := completionFlags(findCommand("menu"))
+	for _, f := range allFlags {
+		if f.value {
+			valueFlags = append(valueFlags, "--"+f.name)
+		}
+	}
+
+	fmt.Fprint(w, "# bash completion for term-type\n_term_type() {\n")
+	fmt.Fprint(w, "    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}\n")
+	fmt.Fprint(w, "    local cmd= i\n")
+	fmt.Fprint(w, "    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprint(w, "        case ${COMP_WORDS[i]} in\n")
+	fmt.Fprintf(w, "            %s) ((i++)) ;;\n", strings.Join(valueFlags, "|"))
+	fmt.Fprint(w, "            -*) ;;\n")
+	fmt.Fprint(w, "            *) cmd=${COMP_WORDS[i]}; break ;;\n")
+	fmt.Fprint(w, "        esac\n    done\n\n")
+
+	fmt.Fprint(w, "    case $prev in\n")
+	for _, f := range allFlags {
+		switch {
+		case f.name == "source-file":
+			fmt.Fprint(w, "        --source-file) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n")
+		case f.value:
+			fmt.Fprintf(w, "        --%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f.name, strings.Join(f.choices, " "))
+		}
+	}
+	fmt.Fprint(w, "    esac\n\n")
+
+	fmt.Fprint(w, "    local words\n    case $cmd in\n")
+	for _, cmd := range commands {
+		var words []string
+		for _, f := range completionFlags(cmd) {
+			words = append(words, "--"+f.name)
+		}
+		words = append(words, commandChoices[cmd.name]...)
+		if cmd.name == "help" {
+			words = names
+		}
+		pattern := strings.Join(commandNames(cmd), "|")
+		if cmd.name == "menu" {
+			pattern = "''|menu"
+			words = append(words, names...)
+		}
+		fmt.Fprintf(w, "        %s) words=%q ;;\n", pattern, strings.Join(append(words, "--help"), " "))
+	}
+	fmt.Fprint(w, "    esac\n    COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n}\n")
+	fmt.Fprint(w, "complete -F _term_type term-type\n")
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprint(w, "#compdef term-type\n\n_term_type() {\n")
+	fmt.Fprint(w, "    local -a commands\n    commands=(\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "        %s\n", zshQuote(cmd.name+":"+cmd.summary))
+	}
+	fmt.Fprint(w, "    )\n\n")
+
+	fmt.Fprint(w, "    local cmd i\n    for ((i = 2; i < CURRENT; i++)); do\n")
+	fmt.Fprint(w, "        if [[ $words[i] != -* ]]; then\n")
+	fmt.Fprint(w, "            [[ $words[i-1] == --* && $words[i-1] != *=* ]] && (( ${+_term_type_values[${words[i-1]}]} )) && continue\n")
+	fmt.Fprint(w, "            cmd=$words[i]; break\n        fi\n    done\n\n")
+
+	fmt.Fprint(w, "    case $cmd in\n")
+	for _, cmd := range commands {
+		pattern := strings.Join(commandNames(cmd), "|")
+		if cmd.name == "menu" {
+			pattern = "''|menu"
+		}
+		fmt.Fprintf(w, "        %s)\n            _arguments \\\n", pattern)
+		for _, f := range completionFlags(cmd) {
+			spec := "--" + f.name
+			if f.value {
+				spec += "=[" + zshEscape(f.usage) + "]:" + f.name + ":"
+				switch {
+				case len(f.choices) > 0:
+					spec += "(" + strings.Join(f.choices, " ") + ")"
+				case f.name == "source-file":
+					spec += "_files"
+				default:
+					spec += " "
+				}
+			} else {
+				spec += "[" + zshEscape(f.usage) + "]"
+			}
+			fmt.Fprintf(w, "                %s \\\n", zshQuote(spec))
+		}
+		switch {
+		case cmd.name == "menu" || cmd.name == "help":
+			fmt.Fprint(w, "                '1:command:{_describe command commands}'\n")
+		case commandChoices[cmd.name] != nil:
+			fmt.Fprintf(w, "                '1:%s:(%s)'\n", cmd.name, strings.Join(commandChoices[cmd.name], " "))
+		default:
+			fmt.Fprint(w, "                '--help[Show help]'\n")
+		}
+		fmt.Fprint(w, "            ;;\n")
+	}
+	fmt.Fprint(w, "    esac\n}\n\n")
+
+	// Flags that take a value, so the loop above skips their values
+	fmt.Fprint(w, "typeset -gA _term_type_values\n_term_type_values=(")
+	for _, f := range completionFlags(findCommand("menu")) {
+		if f.value {
+			fmt.Fprintf(w, " --%s 1", f.name)
+		}
+	}
+	fmt.Fprint(w, " )\n\n_term_type \"$@\"\n")
+}
+
+// zshEscape escapes the characters _arguments treats specially in a
+// description.
+func zshEscape(s string) string {
+	return strings.NewReplacer("[", "\\[", "]", "\\]", ":", "\\:").Replace(s)
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func writeFishCompletion(w io.Writer) {
+	var names []string
+	for _, cmd := range commands {
+		if cmd.name != "menu" {
+			names = append(names, commandNames(cmd)...)
+		}
+	}
+	fmt.Fprint(w, "# fish completion for term-type\ncomplete -c term-type -f\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c term-type -n __fish_use_subcommand -a %s -d %s\n", cmd.name, fishQuote(cmd.summary))
+	}
+	for _, cmd := range commands {
+		condition := "__fish_seen_subcommand_from " + strings.Join(commandNames(cmd), " ")
+		if cmd.name == "menu" {
+			condition = "not __fish_seen_subcommand_from " + strings.Join(names, " ")
+		}
+		fmt.Fprintln(w)
+		for _, f := range completionFlags(cmd) {
+			line := fmt.Sprintf("complete -c term-type -n %s -l %s", fishQuote(condition), f.name)
+			if f.value {
+				line += " -r"
+				if len(f.choices) > 0 {
+					line += " -a " + fishQuote(strings.Join(f.choices, " "))
+				} else if f.name == "source-file" {
+					line += " -F"
+				}
+			}
+			fmt.Fprintln(w, line+" -d "+fishQuote(f.usage))
+		}
+		if choices := commandChoices[cmd.name]; choices != nil {
+			fmt.Fprintf(w, "complete -c term-type -n %s -a %s\n", fishQuote(condition), fishQuote(strings.Join(choices, " ")))
+		}
+	}
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,31 @@
+package main
+
+import "golang.org/x/text/unicode/norm"
+
+// deadKeys maps the spacing accents a terminal sends for dead keys to the
+// combining marks they put on the next letter.
+var deadKeys = map[rune]rune{
+	'`': '\u0300', '´': '\u0301', '^': '\u0302', '~': '\u0303',
+	'¯': '\u0304', '˘': '\u0306', '˙': '\u0307', '¨': '\u0308',
+	'°': '\u030a', '˝': '\u030b', 'ˇ': '\u030c', '¸': '\u0327',
+	'˛': '\u0328',
+}
+
+// composeKeys turns on dead key composition. It's off by default, since
+// terminals on layouts without dead keys send ` ^ and ~ as characters of
+// their own.
+var composeKeys bool
+
+// composeDeadKey returns what a dead key accent followed by ch types: the
+// accented letter if there is one, the accent alone for space or the
+// accent again, and otherwise both keys as typed.
+func composeDeadKey(accent, ch rune) []rune {
+	if ch == ' ' || ch == accent {
+		return []rune{accent}
+	}
+	composed := []rune(norm.NFC.String(string([]rune{ch, deadKeys[accent]})))
+	if len(composed) == 1 {
+		return composed
+	}
+	return []rune{accent, ch}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Config is the user's config file. Its settings are the defaults for the
+// matching command line flags, and can be overridden for one run with
+// TERM_TYPE_<SETTING> environment variables, e.g. TERM_TYPE_THEME=nord.
+type Config struct {
+	Theme string `json:"theme,omitempty"`
+
+	// Mode is what to start when no mode is given: "menu" (the default),
+	// "time N", "words N", "quote" or "zen"
+	Mode string `json:"mode,omitempty"`
+
+	Source      string `json:"source,omitempty"`
+	Language    string `json:"language,omitempty"` // word list
+	Punctuation bool   `json:"punctuation,omitempty"`
+	Numbers     bool   `json:"numbers,omitempty"`
+
+	Layout   string `json:"layout,omitempty"`
+	OSLayout string `json:"os_layout,omitempty"`
+	Keyboard bool   `json:"keyboard,omitempty"`
+	DeadKeys bool   `json:"dead_keys,omitempty"` // compose accents typed with dead keys
+
+	Strictness
+	Matching
+	Fail FailRules `json:"fail,omitzero"`
+	DisplayOptions
+
+	// Menu replaces the menu's built-in tests when set
+	Menu []MenuItem `json:"menu,omitempty"`
+}
+
+// MenuItem is a test on the menu. Options its Test leaves unset come
+// from the command line, so a menu of plain lengths still honors flags
+// like --stop-on.
+type MenuItem struct {
+	Name        string     `json:"name"`
+	Key         string     `json:"key,omitempty"`         // shortcut, a single character
+	Description string     `json:"description,omitempty"` // defaults to a summary of Test
+	Test        TestConfig `json:"test"`
+}
+
+// defaultMenu is the menu used when the config file doesn't set one.
+var defaultMenu = []MenuItem{
+	{Name: "Time 15s", Key: "1", Description: "Timed mode - 15 seconds", Test: TestConfig{TimedMode: true, TimeLimitSec: 15}},
+	{Name: "Time 30s", Key: "2", Description: "Timed mode - 30 seconds", Test: TestConfig{TimedMode: true, TimeLimitSec: 30}},
+	{Name: "Time 60s", Key: "3", Description: "Timed mode - 60 seconds", Test: TestConfig{TimedMode: true, TimeLimitSec: 60}},
+	{Name: "Words 10", Key: "4", Description: "Type 10 words", Test: TestConfig{WordCount: 10}},
+	{Name: "Words 25", Key: "5", Description: "Type 25 words", Test: TestConfig{WordCount: 25}},
+	{Name: "Words 50", Key: "6", Description: "Type 50 words", Test: TestConfig{WordCount: 50}},
+}
+
+// menuKeys are the shortcuts the menu uses for its other entries.
+var menuKeys = "csnzhtoq"
+
+// envPrefix starts the environment variables that override settings.
+const envPrefix = "TERM_TYPE_"
+
+func configPath() string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, _ := os.UserHomeDir()
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "term-type", "config.json")
+}
+
+// readConfig reads the config file, which is optional. Unknown fields are
+// errors so a misspelled option isn't silently ignored.
+func readConfig() (Config, error) {
+	var conf Config
+	path := configPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conf, nil
+		}
+		return conf, err
+	}
+	if err := decodeStrict(data, &conf); err != nil {
+		return conf, fmt.Errorf("%s: %v", path, err)
+	}
+	return conf, nil
+}
+
+func writeConfig(conf Config) error {
+	path := configPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// updateConfig applies change to the config file.
+func updateConfig(change func(*Config)) error {
+	conf, err := readConfig()
+	if err != nil {
+		return err
+	}
+	change(&conf)
+	return writeConfig(conf)
+}
+
+// loadConfig returns the settings in effect: the config file with any
+// environment overrides applied, and the names of TERM_TYPE_ variables
+// that were skipped because they aren't settings. A theme saved by older
+// versions is moved into the config file on the way.
+func loadConfig() (Config, []string, error) {
+	conf, err := readConfig()
+	if err != nil {
+		return conf, nil, err
+	}
+	if legacy := loadThemePreference(); legacy != "" {
+		if conf.Theme == "" {
+			conf.Theme = legacy
+			if err := writeConfig(conf); err != nil {
+				return conf, nil, err
+			}
+		}
+		_ = os.Remove(themeConfigPath())
+	}
+	if err := conf.Validate(); err != nil {
+		return conf, nil, fmt.Errorf("%s: %v", configPath(), err)
+	}
+
+	unknown, err := conf.applyEnv(os.Environ())
+	if err != nil {
+		return conf, unknown, err
+	}
+	if err := conf.Validate(); err != nil {
+		return conf, unknown, fmt.Errorf("environment: %v", err)
+	}
+	return conf, unknown, nil
+}
+
+// applyEnv overrides settings from TERM_TYPE_ variables in env. Values
+// are JSON where they parse as it (numbers, true/false, objects for fail
+// and menu) and plain strings otherwise. Variables that don't name a
+// setting are skipped and returned, since the prefix isn't ours alone.
+func (c *Config) applyEnv(env []string) (unknown []string, err error) {
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, envPrefix) {
+			continue
+		}
+		key := strings.ToLower(strings.TrimPrefix(name, envPrefix))
+
+		// Set the one setting through JSON, so it's named and checked
+		// exactly as it is in the file
+		raw := json.RawMessage(value)
+		if !json.Valid(raw) {
+			raw, _ = json.Marshal(value)
+		}
+		data, _ := json.Marshal(map[string]json.RawMessage{key: raw})
+		err := decodeStrict(data, c)
+		if err != nil && string(raw) == value {
+			// A value like 30 for a name: try it as a string
+			quoted, _ := json.Marshal(value)
+			data, _ = json.Marshal(map[string]json.RawMessage{key: quoted})
+			err = decodeStrict(data, c)
+		}
+		if err != nil {
+			if strings.Contains(err.Error(), "unknown field") {
+				unknown = append(unknown, name)
+				continue
+			}
+			return unknown, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return unknown, nil
+}
+
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func (c Config) Validate() error {
+	if c.Theme != "" {
+		if _, ok := themes[c.Theme]; !ok {
+			return fmt.Errorf("unknown theme %q (choose from %s)", c.Theme, strings.Join(themeOrder, ", "))
+		}
+	}
+	if err := validateMode(c.Mode); err != nil {
+		return err
+	}
+	if c.Source != "" && !isSource(c.Source) {
+		return fmt.Errorf("unknown source %q (choose from %s)", c.Source, strings.Join(sourceNames, ", "))
+	}
+	if err := (WordFilter{Language: c.Language}).Validate(); err != nil {
+		return err
+	}
+	for _, name := range []string{c.Layout, c.OSLayout} {
+		if _, ok := layouts[name]; name != "" && !ok {
+			return fmt.Errorf("unknown layout %q (choose from %s)", name, strings.Join(layoutOrder, ", "))
+		}
+	}
+	if c.Caret != "" && optionIndex(caretStyles, c.Caret) == 0 {
+		return fmt.Errorf("unknown caret style %q (choose from %s)", c.Caret, strings.Join(caretStyles, ", "))
+	}
+	if c.Memory < 0 || c.Lines < 0 {
+		return errors.New("memory and lines can't be negative")
+	}
+	if err := c.Strictness.Validate(); err != nil {
+		return err
+	}
+	if err := c.Matching.Validate(); err != nil {
+		return err
+	}
+	if err := c.Fail.Validate(); err != nil {
+		return err
+	}
+
+	keys := map[string]string{}
+	for i, item := range c.Menu {
+		if err := item.Validate(); err != nil {
+			if item.Name == "" {
+				return fmt.Errorf("menu item %d: %v", i+1, err)
+			}
+			return fmt.Errorf("menu item %d (%q): %v", i+1, item.Name, err)
+		}
+		if item.Key == "" {
+			continue
+		}
+		if other, ok := keys[item.Key]; ok {
+			return fmt.Errorf("menu item %d (%q): key %q is already used by %q", i+1, item.Name, item.Key, other)
+		}
+		keys[item.Key] = item.Name
+	}
+	return nil
+}
+
+func (m MenuItem) Validate() error {
+	if strings.TrimSpace(m.Name) == "" {
+		return errors.New("name is missing")
+	}
+	if m.Key != "" {
+		if utf8.RuneCountInString(m.Key) != 1 {
+			return fmt.Errorf("key %q must be a single character", m.Key)
+		}
+		if strings.Contains(menuKeys, m.Key) {
+			return fmt.Errorf("key %q is used by the menu itself", m.Key)
+		}
+	}
+
+	t := m.Test
+	modes := 0
+	for _, set := range []bool{t.TimedMode, t.WordCount != 0, t.Quote, t.Zen} {
+		if set {
+			modes++
+		}
+	}
+	switch {
+	case modes != 1:
+		return errors.New("test needs exactly one of timed, words, quote or zen")
+	case t.TimedMode && t.TimeLimitSec <= 0:
+		return errors.New("timed test needs a positive time")
+	case !t.TimedMode && t.TimeLimitSec != 0:
+		return errors.New("time is only used with timed")
+	case t.WordCount < 0:
+		return errors.New("words must be a positive number")
+	case t.Source != "" && !isSource(t.Source):
+		return fmt.Errorf("unknown source %q (choose from %s)", t.Source, strings.Join(sourceNames, ", "))
+	}
+	if err := t.Filter.Validate(); err != nil {
+		return err
+	}
+	if err := t.Strictness.Validate(); err != nil {
+		return err
+	}
+	if err := t.Fail.Validate(); err != nil {
+		return err
+	}
+	return t.Matching.Validate()
+}
+
+// validateMode checks a config Mode, which is written like the mode
+// arguments on the command line.
+func validateMode(mode string) error {
+	args := strings.Fields(mode)
+	if len(args) == 0 {
+		return nil
+	}
+	switch args[0] {
+	case "menu", "quote", "zen":
+		if len(args) == 1 {
+			return nil
+		}
+	case "time", "words":
+		if len(args) == 2 {
+			if n, err := strconv.Atoi(args[1]); err == nil && n > 0 {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("mode %q should be menu, time N, words N, quote or zen", mode)
+}
+
+// key returns the item's shortcut, or 0 for none.
+func (m MenuItem) key() rune {
+	r, _ := utf8.DecodeRuneInString(m.Key)
+	if r == utf8.RuneError {
+		return 0
+	}
+	return r
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Most frequent English letter pairs and triples, most common first.
+var topBigrams = []string{
+	"th", "he", "in", "er", "an", "re", "on", "at", "en", "nd",
+	"ti", "es", "or", "te", "of", "ed", "is", "it", "al", "ar",
+	"st", "to", "nt", "ng", "se", "ha", "as", "ou", "io", "le",
+	"ve", "co", "me", "de", "hi", "ri", "ro", "ic", "ne", "ea",
+	"ra", "ce", "li", "ch", "ll", "be", "ma", "si", "om", "ur",
+}
+
+var topTrigrams = []string{
+	"the", "and", "ing", "ion", "tio", "ent", "ati", "for", "her", "ter",
+	"hat", "tha", "ere", "ate", "his", "con", "res", "ver", "all", "ons",
+	"nce", "men", "ith", "ted", "ers", "pro", "thi", "wit", "are", "ess",
+	"not", "ive", "was", "ect", "rea", "com", "eve", "per", "int", "est",
+	"sta", "cti", "ica", "ist", "ear", "ain", "one", "our", "iti", "rat",
+}
+
+// ngramKinds are the drill sources accepted by the ngrams mode.
+var ngramKinds = []string{"bigrams", "trigrams", "slowest"}
+
+// minSlowSamples is how many times a bigram must have been typed before
+// its average latency is trusted for the slowest drill.
+const minSlowSamples = 3
+
+// NgramStat is the typing latency measured for one n-gram.
+type NgramStat struct {
+	Count int     `json:"n"`
+	AvgMs float64 `json:"ms"`
+}
+
+// NgramDrill walks through sets of n-grams, moving to the next set only
+// once a test on the current one meets the accuracy and speed targets.
+type NgramDrill struct {
+	Kind        string
+	Sets        [][]string
+	Set         int // index of the set being practiced
+	Reps        int // times the set is repeated in one test
+	MinAccuracy float64
+	MinWPM      float64
+}
+
+// NgramResult is how one n-gram went in a drill test.
+type NgramResult struct {
+	Ngram    string
+	Accuracy float64
+	WPM      float64
+}
+
+// newNgramDrill builds a drill of the top n n-grams of the given kind,
+// split into sets of setSize.
+func newNgramDrill(kind string, top, setSize, reps int, minAccuracy, minWPM float64) (*NgramDrill, error) {
+	var ngrams []string
+	switch kind {
+	case "bigrams":
+		ngrams = topBigrams
+	case "trigrams":
+		ngrams = topTrigrams
+	case "slowest":
+		results, err := loadHistory()
+		if err != nil {
+			return nil, err
+		}
+		ngrams = slowestBigrams(results)
+		if len(ngrams) < setSize {
+			return nil, fmt.Errorf("not enough typing history yet to find your slowest bigrams")
+		}
+	default:
+		return nil, fmt.Errorf("unknown n-gram kind %q (choose from %s)", kind, strings.Join(ngramKinds, ", "))
+	}
+	if top < len(ngrams) {
+		ngrams = ngrams[:top]
+	}
+
+	d := &NgramDrill{
+		Kind:        kind,
+		Reps:        reps,
+		MinAccuracy: minAccuracy,
+		MinWPM:      minWPM,
+	}
+	for i := 0; i < len(ngrams); i += setSize {
+		end := i + setSize
+		if end > len(ngrams) {
+			end = len(ngrams)
+		}
+		d.Sets = append(d.Sets, ngrams[i:end])
+	}
+	return d, nil
+}
+
+// Text returns the target text for the current set.
+func (d *NgramDrill) Text() string {
+	set := strings.Join(d.Sets[d.Set], " ")
+	parts := make([]string, d.Reps)
+	for i := range parts {
+		parts[i] = set
+	}
+	return strings.Join(parts, " ")
+}
+
+// Passed reports whether a finished test on the current set meets the
+// drill's targets.
+func (d *NgramDrill) Passed(s *TestState) bool {
+	return !s.Failed && s.Accuracy() >= d.MinAccuracy && s.WPM() >= d.MinWPM
+}
+
+// Advance moves to the next set, wrapping around after the last one.
+func (d *NgramDrill) Advance() {
+	d.Set = (d.Set + 1) % len(d.Sets)
+}
+
+func (d *NgramDrill) ModeString() string {
+	return fmt.Sprintf("%s drill %d/%d", d.Kind, d.Set+1, len(d.Sets))
+}
+
+// ngramResults groups a drill test's words by n-gram and reports the
+// accuracy and speed of each, in the order they appear in the set.
+func ngramResults(s *TestState) []NgramResult {
+	type totals struct {
+		correct, typed int
+		dur            time.Duration
+	}
+	byNgram := make(map[string]*totals)
+	var order []string
+	for _, w := range s.wordStats() {
+		t, ok := byNgram[w.word]
+		if !ok {
+			t = &totals{}
+			byNgram[w.word] = t
+			order = append(order, w.word)
+		}
+		t.correct += w.correct
+		t.typed += w.typed
+		t.dur += w.dur
+	}
+
+	var out []NgramResult
+	for _, ng := range order {
+		t := byNgram[ng]
+		r := NgramResult{Ngram: ng, Accuracy: 100}
+		if t.typed > 0 {
+			r.Accuracy = float64(t.correct) / float64(t.typed) * 100
+		}
+		if t.dur > 0 {
+			r.WPM = (float64(t.correct) / 5.0) / t.dur.Minutes()
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// bigramLatencies measures how long each correctly typed letter pair took,
+// from the first letter's keystroke to the second's. Letters count as
+// correct under the test's Matching, so é typed as e still counts.
+func bigramLatencies(s *TestState) map[string]NgramStat {
+	targets := s.targetWords()
+	totals := make(map[string]time.Duration)
+	counts := make(map[string]int)
+	for wi, w := range s.typedWords() {
+		if wi >= len(targets) {
+			break
+		}
+		target := []rune(targets[wi])
+		typed := s.Input[w.start:w.end]
+		for i := 1; i < len(typed) && i < len(target); i++ {
+			a, b := target[i-1], target[i]
+			m := s.Matching
+			if !m.equal(string(typed[i-1]), string(a)) || !m.equal(string(typed[i]), string(b)) || !unicode.IsLetter(a) || !unicode.IsLetter(b) {
+				continue
+			}
+			bg := strings.ToLower(string([]rune{a, b}))
+			totals[bg] += s.KeyTimes[w.start+i].Sub(s.KeyTimes[w.start+i-1])
+			counts[bg]++
+		}
+	}
+
+	out := make(map[string]NgramStat, len(counts))
+	for bg, n := range counts {
+		out[bg] = NgramStat{Count: n, AvgMs: float64(totals[bg].Milliseconds()) / float64(n)}
+	}
+	return out
+}
+
+// slowestBigrams returns the bigrams with the highest average latency
+// across all saved results, slowest first.
+func slowestBigrams(results []Result) []string {
+	totalMs := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, r := range results {
+		for bg, st := range r.Bigrams {
+			totalMs[bg] += st.AvgMs * float64(st.Count)
+			counts[bg] += st.Count
+		}
+	}
+
+	var bigrams []string
+	for bg, n := range counts {
+		if n >= minSlowSamples {
+			bigrams = append(bigrams, bg)
+		}
+	}
+	sort.Slice(bigrams, func(i, j int) bool {
+		ai := totalMs[bigrams[i]] / float64(counts[bigrams[i]])
+		aj := totalMs[bigrams[j]] / float64(counts[bigrams[j]])
+		if ai != aj {
+			return ai > aj
+		}
+		return bigrams[i] < bigrams[j]
+	})
+	return bigrams
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/rivo/uniseg"
 )
 
 // Braille dot positions: each character cell is 2 wide x 4 tall.
@@ -180,22 +181,28 @@ func (g *WPMGraph) Draw(screen tcell.Screen) {
 	// Draw legend on the bottom row, centered
 	if hasErrors {
 		legend := "── wpm  ── errors"
-		legendX := graphX + (graphW-len(legend))/2
+		legendX := graphX + (graphW-uniseg.StringWidth(legend))/2
 		if legendX < graphX {
 			legendX = graphX
 		}
 		wpmLegendLine := "──"
 		errLegendLine := "──"
+		lineW := uniseg.StringWidth(wpmLegendLine)
 		drawString(screen, legendX, graphY+graphH, wpmLegendLine, lineStyle)
-		drawString(screen, legendX+len(wpmLegendLine), graphY+graphH, " wpm  ", axisStyle)
-		drawString(screen, legendX+len(wpmLegendLine)+6, graphY+graphH, errLegendLine, errStyle)
-		drawString(screen, legendX+len(wpmLegendLine)+6+len(errLegendLine), graphY+graphH, " errors", axisStyle)
+		drawString(screen, legendX+lineW, graphY+graphH, " wpm  ", axisStyle)
+		drawString(screen, legendX+lineW+6, graphY+graphH, errLegendLine, errStyle)
+		drawString(screen, legendX+lineW+6+lineW, graphY+graphH, " errors", axisStyle)
 	}
 }
 
+// drawString draws s from (x, y) one grapheme cluster at a time, so wide
+// characters take two cells and combining marks stay with their letter.
 func drawString(screen tcell.Screen, x, y int, s string, style tcell.Style) {
-	for i, ch := range s {
-		screen.SetContent(x+i, y, ch, nil, style)
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		runes := g.Runes()
+		screen.SetContent(x, y, runes[0], runes[1:], style)
+		x += g.Width()
 	}
 }
 
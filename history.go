@@ -2,18 +2,119 @@ package main
 
 import (
 	"encoding/json"
+	"math"
 	"os"
 	"path/filepath"
 	"time"
 )
 
 type Result struct {
-	Date     time.Time `json:"date"`
-	Mode     string    `json:"mode"`
-	WPM      float64   `json:"wpm"`
-	Accuracy float64   `json:"accuracy"`
-	Correct  int       `json:"correct"`
-	Wrong    int       `json:"wrong"`
+	Date     time.Time   `json:"date"`
+	Mode     string      `json:"mode"`
+	WPM      float64     `json:"wpm"`
+	Accuracy float64     `json:"accuracy"`
+	Correct  int         `json:"correct"`
+	Wrong    int         `json:"wrong"`
+	Seed     *int64      `json:"seed,omitempty"` // nil for piped text
+	Source   string      `json:"source,omitempty"`
+	Filter   *WordFilter `json:"filter,omitempty"`
+
+	// Punctuation and numbers change the words a seed gives
+	Punctuation bool `json:"punctuation,omitempty"`
+	Numbers     bool `json:"numbers,omitempty"`
+
+	Strictness *Strictness `json:"strictness,omitempty"`
+	Matching   *Matching   `json:"matching,omitempty"`
+	FailRules  *FailRules  `json:"fail_rules,omitempty"`
+	Failed     bool        `json:"failed,omitempty"`
+	FailReason string      `json:"fail_reason,omitempty"`
+	Paused     bool        `json:"paused,omitempty"` // time while paused isn't counted
+
+	// KeyMS is the average time between keystrokes, reported by zen
+	// tests in place of accuracy
+	KeyMS float64 `json:"key_ms,omitempty"`
+
+	// Bigrams holds per-letter-pair latency, used to build drills from
+	// the pairs you type slowest.
+	Bigrams map[string]NgramStat `json:"bigrams,omitempty"`
+}
+
+// newResult records how the test in s went.
+func newResult(s *TestState) Result {
+	result := Result{
+		Date:     time.Now(),
+		Mode:     s.ModeString(),
+		WPM:      math.Round(s.WPM()),
+		Accuracy: s.Accuracy(),
+		Correct:  s.CorrectChars(),
+		Wrong:    s.WrongChars(),
+		Paused:   s.Pauses > 0,
+	}
+	if s.Generated() {
+		seed := s.Seed
+		result.Seed = &seed
+		result.Source = s.Source
+		result.Punctuation = s.Punctuation
+		result.Numbers = s.Numbers
+		if !s.Filter.IsZero() {
+			filter := s.Filter
+			result.Filter = &filter
+		}
+	}
+	if !s.Strictness.IsZero() {
+		strictness := s.Strictness
+		result.Strictness = &strictness
+	}
+	if !s.Matching.IsZero() {
+		matching := s.Matching
+		result.Matching = &matching
+	}
+	if !s.Fail.IsZero() {
+		fail := s.Fail
+		result.FailRules = &fail
+		result.Failed = s.Failed
+		result.FailReason = s.FailReason
+	}
+	if s.Zen {
+		result.KeyMS = math.Round(float64(s.KeyInterval()) / float64(time.Millisecond))
+	}
+	result.Bigrams = bigramLatencies(s)
+	return result
+}
+
+// Report is the full result of a test, printed by --json.
+type Report struct {
+	Result
+	RawWPM      float64       `json:"raw_wpm"`
+	Consistency float64       `json:"consistency"`
+	Elapsed     float64       `json:"elapsed"` // seconds
+	Snapshots   []WPMSnapshot `json:"snapshots"`
+
+	// KeyTimes are when each key of a zen test's text was typed, in
+	// seconds from the start
+	KeyTimes []float64 `json:"key_times,omitempty"`
+}
+
+func newReport(s *TestState, result Result) *Report {
+	r := &Report{
+		Result:      result,
+		RawWPM:      math.Round(s.RawWPM()),
+		Consistency: math.Round(s.Consistency()*10) / 10,
+		Elapsed:     s.Elapsed().Seconds(),
+		Snapshots:   s.WPMSnapshots,
+	}
+	if r.Snapshots == nil {
+		r.Snapshots = []WPMSnapshot{}
+	}
+	if s.Zen {
+		r.KeyTimes = []float64{}
+		for _, t := range s.KeyTimes {
+			r.KeyTimes = append(r.KeyTimes, t.Sub(s.StartTime).Seconds())
+		}
+	}
+	// Latencies are for building drills, not reporting
+	r.Bigrams = nil
+	return r
 }
 
 func historyPath() string {
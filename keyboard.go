@@ -0,0 +1,139 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Keyboard geometry. Rows are shifted right to approximate the stagger of
+// a real keyboard; offsets are in quarter keys so they scale with the key
+// width.
+const (
+	keyboardHeight = 5
+	spaceKeys      = 6 // width of the space bar, in keys
+)
+
+var rowOffsets = [4]int{0, 6, 7, 9}
+
+// How long a key stays lit after it's pressed.
+const (
+	keyFlash      = 150 * time.Millisecond
+	keyErrorFlash = 400 * time.Millisecond
+)
+
+// KeyboardView is a tview primitive that draws a keyboard for a layout,
+// highlights the key for the next character of the test, and briefly
+// lights up keys as they're pressed.
+type KeyboardView struct {
+	*tview.Box
+	layout KeyboardLayout
+	state  *TestState
+}
+
+func NewKeyboardView(layoutName string, state *TestState) *KeyboardView {
+	kv := &KeyboardView{
+		Box:    tview.NewBox(),
+		layout: layouts[layoutName],
+		state:  state,
+	}
+	kv.SetBackgroundColor(colorBackground)
+	return kv
+}
+
+func (k *KeyboardView) Draw(screen tcell.Screen) {
+	k.Box.DrawForSubclass(screen, k)
+	x, y, width, height := k.GetInnerRect()
+	if height < keyboardHeight {
+		return
+	}
+
+	// Use the widest keys that fit: " q " plus a gap, then "q " plus a
+	// gap, then a bare "q" plus a gap
+	topKeys := len([]rune(k.layout.Rows[rowTop]))
+	keyW := 0
+	for w := 4; w >= 2; w-- {
+		if rowOffsets[rowTop]*w/4+topKeys*w-1 <= width {
+			keyW = w
+			break
+		}
+	}
+	if keyW == 0 {
+		return
+	}
+	kbWidth := rowOffsets[rowTop]*keyW/4 + topKeys*keyW - 1
+	left := x + (width-kbWidth)/2
+
+	keyStyle := tcell.StyleDefault.
+		Background(blendColors(colorBackground, colorSubtle, 0.25)).
+		Foreground(colorCorrect)
+	nextStyle := tcell.StyleDefault.Background(colorAccent).Foreground(colorBackground)
+	pressStyle := tcell.StyleDefault.Background(colorCorrect).Foreground(colorBackground)
+	errorStyle := tcell.StyleDefault.Background(colorWrongFg).Foreground(colorBackground)
+
+	next := k.state.NextRune()
+	pressed := rune(0)
+	pressedStyle := pressStyle
+	since := time.Since(k.state.LastKeyTime)
+	if k.state.LastKeyWrong && !display.Blind && since < keyErrorFlash {
+		pressed, pressedStyle = k.state.LastKey, errorStyle
+	} else if since < keyFlash {
+		pressed = k.state.LastKey
+	}
+
+	styleFor := func(chars ...rune) tcell.Style {
+		for _, ch := range chars {
+			if pressed != 0 && ch == pressed {
+				return pressedStyle
+			}
+		}
+		for _, ch := range chars {
+			if next != 0 && ch == next {
+				return nextStyle
+			}
+		}
+		return keyStyle
+	}
+
+	for r, row := range k.layout.Rows {
+		shifted := []rune(k.layout.Shifted[r])
+		for c, ch := range []rune(row) {
+			chars := []rune{ch}
+			if c < len(shifted) {
+				chars = append(chars, shifted[c])
+			}
+			style := styleFor(chars...)
+			label := string(ch)
+			switch keyW {
+			case 4:
+				label = " " + label + " "
+			case 3:
+				label += " "
+			}
+			kx := left + rowOffsets[r]*keyW/4 + c*keyW
+			drawString(screen, kx, y+r, label, style)
+		}
+	}
+
+	// Space bar, under the middle of the bottom row
+	style := styleFor(' ')
+	sx := left + rowOffsets[rowBottom]*keyW/4 + 2*keyW
+	for i := 0; i < spaceKeys*keyW-1; i++ {
+		screen.SetContent(sx+i, y+4, ' ', nil, style)
+	}
+}
+
+// withKeyboard returns the page for a typing test: the typing box, with
+// the on-screen keyboard under it when it's enabled.
+func withKeyboard(tb *TypingBox, state *TestState) tview.Primitive {
+	if keyboardLayout == "" {
+		return tb
+	}
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(tb, 0, 1, true).
+		AddItem(NewKeyboardView(keyboardLayout, state), keyboardHeight, 0, false).
+		AddItem(nil, 1, 0, false)
+	flex.SetBackgroundColor(colorBackground)
+	return flex
+}
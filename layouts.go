@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyboardLayout lists the characters on each key of a standard ANSI
+// keyboard for one layout. Rows run number, top, home, bottom; each row's
+// characters run left to right, unshifted in Rows and shifted in Shifted.
+type KeyboardLayout struct {
+	Rows    [4]string
+	Shifted [4]string
+}
+
+const (
+	rowNumber = iota
+	rowTop
+	rowHome
+	rowBottom
+)
+
+// handSplit is the first column typed by the right hand on every row.
+const handSplit = 5
+
+var layouts = map[string]KeyboardLayout{
+	"qwerty": {
+		Rows:    [4]string{"1234567890-=", "qwertyuiop[]", "asdfghjkl;'", "zxcvbnm,./"},
+		Shifted: [4]string{"!@#$%^&*()_+", "QWERTYUIOP{}", `ASDFGHJKL:"`, "ZXCVBNM<>?"},
+	},
+	"dvorak": {
+		Rows:    [4]string{"1234567890[]", "',.pyfgcrl/=", "aoeuidhtns-", ";qjkxbmwvz"},
+		Shifted: [4]string{"!@#$%^&*(){}", `"<>PYFGCRL?+`, "AOEUIDHTNS_", ":QJKXBMWVZ"},
+	},
+	"colemak": {
+		Rows:    [4]string{"1234567890-=", "qwfpgjluy;[]", "arstdhneio'", "zxcvbkm,./"},
+		Shifted: [4]string{"!@#$%^&*()_+", "QWFPGJLUY:{}", `ARSTDHNEIO"`, "ZXCVBKM<>?"},
+	},
+	"colemak-dh": {
+		Rows:    [4]string{"1234567890-=", "qwfpbjluy;[]", "arstgmneio'", "zxcdvkh,./"},
+		Shifted: [4]string{"!@#$%^&*()_+", "QWFPBJLUY:{}", `ARSTGMNEIO"`, "ZXCDVKH<>?"},
+	},
+	"workman": {
+		Rows:    [4]string{"1234567890-=", "qdrwbjfup;[]", "ashtgyneoi'", "zxmcvkl,./"},
+		Shifted: [4]string{"!@#$%^&*()_+", "QDRWBJFUP:{}", `ASHTGYNEOI"`, "ZXMCVKL<>?"},
+	},
+}
+
+var layoutOrder = []string{"qwerty", "dvorak", "colemak", "colemak-dh", "workman"}
+
+// keyGroups names the rows and hands that --keys can combine.
+var keyGroups = []string{"top", "home", "bottom", "numbers", "left", "right"}
+
+// layoutKeys returns the unshifted characters typed with the keys in
+// groups, a "+"-separated list such as "home+top" or "left". Row groups
+// add rows and hand groups narrow them to one side, so "left+home" is the
+// left half of the home row. With no row group, all three letter rows are
+// used.
+func layoutKeys(layoutName, groups string) (string, error) {
+	layout, ok := layouts[layoutName]
+	if !ok {
+		return "", fmt.Errorf("unknown layout %q (choose from %s)", layoutName, strings.Join(layoutOrder, ", "))
+	}
+
+	var rows [4]bool
+	anyRow := false
+	left, right := false, false
+	for _, g := range strings.Split(groups, "+") {
+		switch strings.TrimSpace(g) {
+		case "numbers":
+			rows[rowNumber], anyRow = true, true
+		case "top":
+			rows[rowTop], anyRow = true, true
+		case "home":
+			rows[rowHome], anyRow = true, true
+		case "bottom":
+			rows[rowBottom], anyRow = true, true
+		case "left":
+			left = true
+		case "right":
+			right = true
+		default:
+			return "", fmt.Errorf("unknown key group %q (combine %s with +)", g, strings.Join(keyGroups, ", "))
+		}
+	}
+	if !anyRow {
+		rows[rowTop], rows[rowHome], rows[rowBottom] = true, true, true
+	}
+	if !left && !right {
+		left, right = true, true
+	}
+
+	var b strings.Builder
+	for r, row := range layout.Rows {
+		if !rows[r] {
+			continue
+		}
+		for col, ch := range []rune(row) {
+			if (col < handSplit && left) || (col >= handSplit && right) {
+				b.WriteRune(ch)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// keyRemap translates runes typed on the OS keyboard layout into the
+// layout being practiced, so a QWERTY system can be used to learn another
+// layout. It's nil when no translation is needed.
+var keyRemap map[rune]rune
+
+// keyboardLayout is the layout drawn on the on-screen keyboard, or "" when
+// the keyboard is hidden. It's shown for --keyboard, or whenever --layout
+// is practicing a layout.
+var keyboardLayout string
+
+// remapTable maps each character of the from layout to the character on
+// the same key in the to layout.
+func remapTable(from, to string) map[rune]rune {
+	if from == to {
+		return nil
+	}
+	f, t := layouts[from], layouts[to]
+	m := make(map[rune]rune)
+	add := func(fromRow, toRow string) {
+		toRunes := []rune(toRow)
+		for i, r := range []rune(fromRow) {
+			if i < len(toRunes) {
+				m[r] = toRunes[i]
+			}
+		}
+	}
+	for r := range f.Rows {
+		add(f.Rows[r], t.Rows[r])
+		add(f.Shifted[r], t.Shifted[r])
+	}
+	return m
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -14,141 +16,21 @@ import (
 	"github.com/rivo/tview"
 )
 
-func usage() {
-	fmt.Fprintf(os.Stderr, `Usage: term-type [--theme NAME] [mode]
-
-Modes:
-  (none)       Open interactive menu
-  time N       Timed mode (N seconds)
-  words N      Word count mode (N words)
-  history      Show history
-  clear history  Clear history
-  clear theme    Reset theme to default
-  themes       List available themes
-
-Options:
-  --theme NAME   Set color theme (auto-detects Omarchy theme by default)
-
-Piped input:
-  echo "custom text" | term-type
-  cat quote.txt | term-type
-
-Examples:
-  term-type
-  term-type --theme catppuccin time 30
-  term-type words 25
-  term-type themes
-`)
-	os.Exit(1)
-}
-
-func parseArgs() (mode string, timedMode bool, timeLimitSec int, wordCount int, themeName string) {
-	args := os.Args[1:]
-
-	// Extract --theme flag
-	var filtered []string
-	for i := 0; i < len(args); i++ {
-		if args[i] == "--theme" {
-			if i+1 < len(args) {
-				themeName = args[i+1]
-				i++ // skip value
-			} else {
-				fmt.Fprintf(os.Stderr, "Error: --theme requires a theme name\n")
-				os.Exit(1)
-			}
-		} else {
-			filtered = append(filtered, args[i])
-		}
-	}
-	args = filtered
-
-	if len(args) == 0 {
-		return "menu", false, 0, 0, themeName
-	}
-
-	switch args[0] {
-	case "time", "t":
-		if len(args) < 2 {
-			usage()
-		}
-		n, err := strconv.Atoi(args[1])
-		if err != nil || n <= 0 {
-			fmt.Fprintf(os.Stderr, "Error: time must be a positive number of seconds\n")
-			os.Exit(1)
-		}
-		// Generate roughly 3-4 words per second of typing
-		wc := n * 4
-		return "test", true, n, wc, themeName
-	case "words", "w":
-		if len(args) < 2 {
-			usage()
-		}
-		n, err := strconv.Atoi(args[1])
-		if err != nil || n <= 0 {
-			fmt.Fprintf(os.Stderr, "Error: words must be a positive number\n")
-			os.Exit(1)
-		}
-		return "test", false, 0, n, themeName
-	case "history", "h":
-		return "history", false, 0, 0, themeName
-	case "themes":
-		fmt.Println("Available themes:")
-		for _, name := range themeOrder {
-			fmt.Printf("  %s\n", name)
-		}
-		detected := detectOmarchyTheme()
-		if detected != "" {
-			fmt.Printf("\nCurrent Omarchy theme: %s\n", detected)
-		}
-		os.Exit(0)
-	case "clear":
-		if len(args) < 2 {
-			fmt.Fprintf(os.Stderr, "Usage: term-type clear <history|theme>\n")
-			os.Exit(1)
-		}
-		switch args[1] {
-		case "history":
-			if err := clearHistory(); err != nil {
-				fmt.Fprintf(os.Stderr, "Error clearing history: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Println("History cleared.")
-		case "theme":
-			if err := clearThemePreference(); err != nil {
-				fmt.Fprintf(os.Stderr, "Error clearing theme: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Println("Theme reset to default.")
-		default:
-			fmt.Fprintf(os.Stderr, "Unknown clear target: %s\n", args[1])
-			fmt.Fprintf(os.Stderr, "Usage: term-type clear <history|theme>\n")
-			os.Exit(1)
-		}
-		os.Exit(0)
-	case "--help", "-h":
-		usage()
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown mode: %s\n", args[0])
-		usage()
-	}
-	return "menu", false, 0, 0, themeName
-}
-
 // readPipedInput reads from stdin if it's a pipe, normalizes whitespace,
 // then reopens /dev/tty so tcell can read keyboard input.
-func readPipedInput() (string, bool) {
+func readPipedInput() (string, bool, error) {
 	info, err := os.Stdin.Stat()
 	if err != nil {
-		return "", false
+		return "", false, nil
 	}
 	if info.Mode()&os.ModeCharDevice != 0 {
 		// stdin is a terminal, not a pipe
-		return "", false
+		return "", false, nil
 	}
 
 	data, err := io.ReadAll(os.Stdin)
 	if err != nil || len(data) == 0 {
-		return "", false
+		return "", false, nil
 	}
 
 	// Normalize: collapse all whitespace into single spaces, trim
@@ -168,25 +50,107 @@ func readPipedInput() (string, bool) {
 	}
 	text = b.String()
 	if text == "" {
-		return "", false
+		return "", false, nil
 	}
 
 	// Reopen /dev/tty as stdin so tcell gets keyboard input
 	tty, err := os.Open("/dev/tty")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: cannot open /dev/tty for keyboard input: %v\n", err)
-		os.Exit(1)
+		return "", false, fmt.Errorf("cannot open /dev/tty for keyboard input: %v", err)
 	}
 	os.Stdin = tty
 
-	return text, true
+	return text, true, nil
+}
+
+// applyOptions sets up the theme, display and keyboard for opts.
+func applyOptions(opts options) {
+	initTheme(opts.theme)
+	display = opts.display
+	composeKeys = opts.deadKeys
+
+	keyboardLayout, keyRemap = "", nil
+	if opts.keyboard {
+		keyboardLayout = "qwerty"
+	}
+	if opts.layout != "" {
+		osLayout := opts.osLayout
+		if osLayout == "" {
+			osLayout = "qwerty"
+		}
+		keyboardLayout = opts.layout
+		keyRemap = remapTable(osLayout, opts.layout)
+	}
 }
 
 func main() {
-	pipedText, hasPiped := readPipedInput()
-	mode, argTimedMode, argTimeLimitSec, argWordCount, themeName := parseArgs()
+	var conf Config
+	if usesConfig(os.Args[1:]) {
+		var unknown []string
+		var err error
+		conf, unknown, err = loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range unknown {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s: not a setting\n", name)
+		}
+	}
+	inv, err := parseArgs(os.Args[1:], conf)
+	if errors.Is(err, flag.ErrHelp) {
+		printUsage(os.Stdout, inv.cmd)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if inv.cmd != nil && inv.cmd.name != "menu" {
+			fmt.Fprintf(os.Stderr, "Run 'term-type %s --help' for usage.\n", inv.cmd.name)
+		} else {
+			fmt.Fprintf(os.Stderr, "Run 'term-type --help' for usage.\n")
+		}
+		os.Exit(2)
+	}
+	if done, err := runCommand(inv, os.Stdout); done {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	opts := inv.opts
+	mode := inv.cmd.name
 
-	initTheme(themeName)
+	pipedText, hasPiped, err := readPipedInput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	menuItems := conf.Menu
+	if len(menuItems) == 0 {
+		menuItems = defaultMenu
+	}
+	applyOptions(opts)
+
+	if opts.sourceFile != "" {
+		data, err := os.ReadFile(opts.sourceFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading source file: %v\n", err)
+			os.Exit(1)
+		}
+		markovModel = trainMarkov(string(data))
+		if markovModel == nil {
+			fmt.Fprintf(os.Stderr, "Error: %s has too few words to train on\n", opts.sourceFile)
+			os.Exit(1)
+		}
+		if opts.source == "" {
+			opts.source = "markov"
+		}
+	}
+	source := opts.source
+	if source == "" {
+		source = "words"
+	}
 
 	// Piped input overrides mode
 	if hasPiped {
@@ -205,36 +169,161 @@ func main() {
 		app.SetScreen(screen)
 	}
 
+	// The bar caret changes the terminal's cursor, so put it back for
+	// every screen but the typing box, which sets it again as it draws
+	app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		screen.SetCursorStyle(tcell.CursorStyleDefault, tcell.ColorReset)
+		return false
+	})
+
 	pages := tview.NewPages()
 
 	var (
 		currentState *TestState
 		ticker       *time.Ticker
 		stopTimer    chan struct{}
+		report       *Report // the finished test, for --json
 	)
 
 	// Forward declarations for mutual references
-	var startTest func(timedMode bool, timeLimitSec int, wordCount int)
-	var startTestWithText func(text string)
+	var startTest func(cfg TestConfig)
+	var startTestWithText func(text string, drill *NgramDrill)
+	var startDrill func()
 	var showResults func()
 	var showHistory func()
 	var showThemes func()
+	var showCustom func()
+	var showSettings func()
 	var rebuildMenu func()
 
+	// startMenuItem starts one of the menu's tests, filling in what it
+	// leaves unset from the current source and the command line
+	startMenuItem := func(item MenuItem) {
+		cfg := item.Test
+		if cfg.Source == "" {
+			cfg.Source = source
+		}
+		if cfg.Filter.IsZero() {
+			cfg.Filter = opts.filter
+		}
+		if cfg.Strictness.IsZero() {
+			cfg.Strictness = opts.strictness
+		}
+		if cfg.Fail.IsZero() {
+			cfg.Fail = opts.fail
+		} else if cfg.Fail.MinWPM > 0 && cfg.Fail.MinWPMAfter == 0 {
+			cfg.Fail.MinWPMAfter = defaultMinWPMAfter
+		}
+		if cfg.Matching.IsZero() {
+			cfg.Matching = opts.matching
+		}
+		cfg.Punctuation = cfg.Punctuation || opts.punctuation
+		cfg.Numbers = cfg.Numbers || opts.numbers
+		startTest(cfg)
+	}
+
+	removeSaved := func(name string) {
+		if err := deletePreset(name); err != nil {
+			showMessage(pages, "Can't remove preset", err.Error())
+			return
+		}
+		rebuildMenu()
+	}
+
+	cycleSource := func() string {
+		for i, name := range sourceNames {
+			if name == source {
+				source = sourceNames[(i+1)%len(sourceNames)]
+				break
+			}
+		}
+		return source
+	}
+
+	// A broken presets file shouldn't keep the menu from opening, but
+	// the saved tests missing from it needs explaining
 	rebuildMenu = func() {
-		menu := buildMenu(app, pages, startTest, showHistory, showThemes)
+		presets, err := loadPresets()
+		menu := buildMenu(app, pages, menuItems, startMenuItem, startDrill, showCustom, showHistory, showThemes, showSettings, source, cycleSource, presets, startTest, removeSaved)
 		pages.AddAndSwitchToPage("menu", menu, true)
+		if err != nil {
+			showMessage(pages, "Can't read presets", fmt.Sprintf("%s: %v", presetsPath(), err))
+		}
 	}
 
 	showThemes = func() {
 		picker := buildThemePicker(app, pages, func(name string) {
 			initTheme(name)
-			saveThemePreference(name)
+			if err := saveThemePreference(name); err != nil {
+				showMessage(pages, "Can't save theme", err.Error())
+			}
 			rebuildMenu()
 		})
 		pages.AddAndSwitchToPage("themes", picker, true)
 	}
 
+	showSettings = func() {
+		fileConf, err := readConfig()
+		if err != nil {
+			showMessage(pages, "Can't read settings", err.Error())
+			return
+		}
+		settings := buildSettings(app, pages, fileConf, func(edited Config) {
+			// The new settings apply under the variables and flags that
+			// override them, so check them together before saving
+			conf := edited
+			_, err := conf.applyEnv(os.Environ())
+			if err == nil {
+				err = conf.Validate()
+			}
+			var merged options
+			if err == nil {
+				merged, err = inv.withConfig(conf)
+			}
+			if err != nil {
+				showMessage(pages, "Can't save settings", err.Error())
+				return
+			}
+			if err := writeConfig(edited); err != nil {
+				showMessage(pages, "Can't save settings", err.Error())
+				return
+			}
+
+			// Apply them now
+			opts = merged
+			if opts.sourceFile != "" && opts.source == "" {
+				opts.source = "markov"
+			}
+			menuItems = conf.Menu
+			if len(menuItems) == 0 {
+				menuItems = defaultMenu
+			}
+			applyOptions(opts)
+			source = opts.source
+			if source == "" {
+				source = "words"
+			}
+			rebuildMenu()
+		})
+		pages.AddAndSwitchToPage("settings", settings, true)
+	}
+
+	showCustom = func() {
+		defaults := TestConfig{WordCount: 25, Source: source, Filter: opts.filter, Strictness: opts.strictness, Fail: opts.fail, Matching: opts.matching, Punctuation: opts.punctuation, Numbers: opts.numbers}
+		if defaults.Filter.Layout == "" {
+			defaults.Filter.Layout = opts.layout
+		}
+		onSave := func(p Preset) {
+			if err := savePreset(p); err != nil {
+				showMessage(pages, "Can't save preset", err.Error())
+				return
+			}
+			rebuildMenu()
+		}
+		form := buildCustomForm(app, pages, defaults, startTest, onSave)
+		pages.AddAndSwitchToPage("custom", form, true)
+	}
+
 	showHistory = func() {
 		histPage := buildHistory(app, pages, func() {
 			showHistory()
@@ -248,103 +337,46 @@ func main() {
 		}
 		currentState.Finish()
 
-		// Save result
-		_ = saveResult(Result{
-			Date:     time.Now(),
-			Mode:     currentState.ModeString(),
-			WPM:      math.Round(currentState.WPM()),
-			Accuracy: currentState.Accuracy(),
-			Correct:  currentState.CorrectChars(),
-			Wrong:    currentState.WrongChars(),
-		})
+		result := newResult(currentState)
+		_ = saveResult(result)
+
+		// With --json the results go to stdout once the app has closed
+		if opts.json {
+			report = newReport(currentState, result)
+			app.Stop()
+			return
+		}
 
 		resultsPage := buildResults(app, pages, currentState, func() {
-			// Retry with same settings
-			if currentState.PipedText != "" {
-				startTestWithText(currentState.PipedText)
+			// Retry with same settings, or move on to the next drill set
+			if d := currentState.Drill; d != nil {
+				if d.Passed(currentState) {
+					d.Advance()
+				}
+				startTestWithText(d.Text(), d)
+			} else if currentState.PipedText != "" {
+				startTestWithText(currentState.PipedText, nil)
 			} else {
-				startTest(currentState.TimedMode, currentState.TimeLimitSec, currentState.WordCount)
+				startTest(currentState.TestConfig)
 			}
 		}, showHistory)
 		pages.AddAndSwitchToPage("results", resultsPage, true)
 	}
 
-	// startTestWithText starts a typing test using provided text (for piped input)
-	startTestWithText = func(text string) {
-		// Stop any existing timer
-		if stopTimer != nil {
-			close(stopTimer)
-			stopTimer = nil
-		}
-		if ticker != nil {
-			ticker.Stop()
-			ticker = nil
-		}
-
-		wordCount := len(strings.Fields(text))
-		currentState = NewTestState(text, false, 0, wordCount)
-		currentState.PipedText = text
+	newDrill := func() (*NgramDrill, error) {
+		return newNgramDrill(opts.ngrams, opts.top, opts.setSize, opts.reps, opts.minAccuracy, opts.minWPM)
+	}
 
-		onFinish := func() {
-			if stopTimer != nil {
-				close(stopTimer)
-				stopTimer = nil
-			}
-			if ticker != nil {
-				ticker.Stop()
-				ticker = nil
-			}
-			showResults()
-		}
-		onEscape := func() {
-			if stopTimer != nil {
-				close(stopTimer)
-				stopTimer = nil
-			}
-			if ticker != nil {
-				ticker.Stop()
-				ticker = nil
-			}
-			pages.SwitchToPage("menu")
+	startDrill = func() {
+		d, err := newDrill()
+		if err != nil {
+			showMessage(pages, "N-gram drill", err.Error())
+			return
 		}
-
-		typingBox := NewTypingBox(currentState, onFinish, onEscape)
-		pages.AddAndSwitchToPage("typing", typingBox, true)
-
-		// Start WPM sampling goroutine
-		stopTimer = make(chan struct{})
-		go func(state *TestState, stop chan struct{}) {
-			for !state.Started {
-				select {
-				case <-stop:
-					return
-				case <-time.After(50 * time.Millisecond):
-				}
-			}
-
-			wpmTicker := time.NewTicker(1 * time.Second)
-			defer wpmTicker.Stop()
-
-			for {
-				select {
-				case <-stop:
-					return
-				case <-wpmTicker.C:
-					if state.Finished {
-						return
-					}
-					state.WPMSnapshots = append(state.WPMSnapshots, WPMSnapshot{
-						Elapsed: state.Elapsed().Seconds(),
-						WPM:     state.WPM(),
-						Errors:  state.WrongChars(),
-					})
-				}
-			}
-		}(currentState, stopTimer)
+		startTestWithText(d.Text(), d)
 	}
 
-	startTest = func(timedMode bool, timeLimitSec int, wordCount int) {
-		// Stop any existing timer
+	stopTimers := func() {
 		if stopTimer != nil {
 			close(stopTimer)
 			stopTimer = nil
@@ -353,39 +385,30 @@ func main() {
 			ticker.Stop()
 			ticker = nil
 		}
+	}
 
-		target := pickWords(wordCount)
-		currentState = NewTestState(target, timedMode, timeLimitSec, wordCount)
+	// runTest shows the typing page for state and starts the goroutine
+	// that samples WPM, redraws, and ends timed tests
+	runTest := func(state *TestState) {
+		stopTimers()
+		currentState = state
 
 		onFinish := func() {
-			if stopTimer != nil {
-				close(stopTimer)
-				stopTimer = nil
-			}
-			if ticker != nil {
-				ticker.Stop()
-				ticker = nil
-			}
+			stopTimers()
 			showResults()
 		}
-
 		onEscape := func() {
-			if stopTimer != nil {
-				close(stopTimer)
-				stopTimer = nil
-			}
-			if ticker != nil {
-				ticker.Stop()
-				ticker = nil
+			stopTimers()
+			if opts.json {
+				app.Stop()
+				return
 			}
 			pages.SwitchToPage("menu")
 		}
 
-		typingBox := NewTypingBox(currentState, onFinish, onEscape)
-
-		pages.AddAndSwitchToPage("typing", typingBox, true)
+		typingBox := NewTypingBox(state, onFinish, onEscape)
+		pages.AddAndSwitchToPage("typing", withKeyboard(typingBox, state), true)
 
-		// Start a goroutine for WPM sampling (both modes) and timed countdown
 		stopTimer = make(chan struct{})
 		go func(state *TestState, stop chan struct{}) {
 			// Wait for test to start
@@ -413,6 +436,9 @@ func main() {
 					if state.Finished {
 						return
 					}
+					if state.Paused {
+						continue
+					}
 					state.WPMSnapshots = append(state.WPMSnapshots, WPMSnapshot{
 						Elapsed: state.Elapsed().Seconds(),
 						WPM:     state.WPM(),
@@ -422,7 +448,11 @@ func main() {
 					if state.Finished {
 						return
 					}
-					if timedMode && state.TimeRemaining() <= 0 {
+					// The clock stands still while paused
+					if state.Paused {
+						continue
+					}
+					if state.TimedMode && state.TimeRemaining() <= 0 {
 						app.QueueUpdateDraw(func() {
 							if !state.Finished {
 								onFinish()
@@ -430,20 +460,111 @@ func main() {
 						})
 						return
 					}
-					app.QueueUpdateDraw(func() {})
+					app.QueueUpdateDraw(func() {
+						// Speed can drop below a fail threshold while idle
+						if !state.Finished {
+							state.CheckFail()
+							if state.Failed {
+								onFinish()
+							}
+						}
+					})
 				}
 			}
-		}(currentState, stopTimer)
+		}(state, stopTimer)
 	}
 
-	menu := buildMenu(app, pages, startTest, showHistory, showThemes)
-	pages.AddPage("menu", menu, true, true)
+	// startTestWithText starts a typing test using provided text (for piped
+	// input and n-gram drills)
+	startTestWithText = func(text string, drill *NgramDrill) {
+		wordCount := len(strings.Fields(text))
+		state := NewTestState(text, TestConfig{
+			WordCount:  wordCount,
+			Strictness: opts.strictness,
+			Fail:       opts.fail,
+			Matching:   opts.matching,
+		})
+		if drill != nil {
+			state.Drill = drill
+		} else {
+			state.PipedText = text
+		}
+		runTest(state)
+	}
+
+	startTest = func(cfg TestConfig) {
+		// Every generated test gets a seed so it can be reproduced later;
+		// --seed pins it so retries repeat the same words.
+		seed := newSeed()
+		if opts.hasSeed {
+			seed = opts.seed
+		}
+		gen, err := newTextGenerator(cfg, seed)
+		if err == nil {
+			// Saved presets are only checked when they're used
+			err = cfg.Fail.Validate()
+		}
+		if err != nil {
+			showMessage(pages, "Can't start test", err.Error())
+			return
+		}
+
+		// Timed tests generate text as they go; word tests need it all now
+		var state *TestState
+		switch {
+		case cfg.Quote:
+			text := gen.Quote()
+			cfg.WordCount = len(strings.Fields(text))
+			state = NewTestState(text, cfg)
+		case cfg.Zen:
+			state = NewTestState("", cfg)
+		case cfg.TimedMode:
+			state = NewTimedTestState(gen, cfg)
+		default:
+			state = NewTestState(gen.Next(cfg.WordCount), cfg)
+		}
+		state.Seed = seed
+		runTest(state)
+	}
+
+	rebuildMenu()
 
 	switch mode {
-	case "test":
-		startTest(argTimedMode, argTimeLimitSec, argWordCount)
+	case "time", "words":
+		cfg := TestConfig{
+			Source:      source,
+			Filter:      opts.filter,
+			Strictness:  opts.strictness,
+			Fail:        opts.fail,
+			Matching:    opts.matching,
+			Punctuation: opts.punctuation,
+			Numbers:     opts.numbers,
+		}
+		n, _ := strconv.Atoi(inv.args[0])
+		if mode == "time" {
+			cfg.TimedMode, cfg.TimeLimitSec = true, n
+		} else {
+			cfg.WordCount = n
+		}
+		startTest(cfg)
+	case "quote":
+		startTest(TestConfig{
+			Quote:      true,
+			Strictness: opts.strictness,
+			Fail:       opts.fail,
+			Matching:   opts.matching,
+		})
+	case "zen":
+		startTest(TestConfig{Zen: true})
 	case "pipe":
-		startTestWithText(pipedText)
+		startTestWithText(pipedText, nil)
+	case "ngrams":
+		d, err := newDrill()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		startTestWithText(d.Text(), d)
 	case "history":
 		showHistory()
 	}
@@ -454,4 +575,17 @@ func main() {
 	if err := app.Run(); err != nil {
 		panic(err)
 	}
+
+	if opts.json {
+		if report == nil {
+			fmt.Fprintln(os.Stderr, "Error: test quit before it finished")
+			os.Exit(1)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 }
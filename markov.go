@@ -0,0 +1,110 @@
+package main
+
+import (
+	_ "embed"
+	"math/rand"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+//go:embed corpus.txt
+var corpusText string
+
+// markovOrder is how many previous words decide the next one. Two keeps
+// the output grammatical-looking without copying whole sentences from a
+// corpus the size of corpus.txt.
+const markovOrder = 2
+
+type markovKey [markovOrder]string
+
+// MarkovChain is a word-level n-gram model trained on a body of prose.
+type MarkovChain struct {
+	next   map[markovKey][]string
+	starts []markovKey // keys that open a sentence
+}
+
+// markovModel is the chain used by the "markov" word source. It's trained
+// from corpus.txt on first use unless a --source-file replaced it.
+var markovModel *MarkovChain
+
+func getMarkovModel() *MarkovChain {
+	if markovModel == nil {
+		markovModel = trainMarkov(corpusText)
+	}
+	return markovModel
+}
+
+// trainMarkov builds a chain from text. It returns nil if the text is too
+// short to generate anything from.
+func trainMarkov(text string) *MarkovChain {
+	words := strings.Fields(text)
+	if len(words) <= markovOrder {
+		return nil
+	}
+
+	m := &MarkovChain{next: make(map[markovKey][]string)}
+	for i := 0; i+markovOrder < len(words); i++ {
+		var key markovKey
+		copy(key[:], words[i:i+markovOrder])
+		m.next[key] = append(m.next[key], words[i+markovOrder])
+
+		if i == 0 || endsSentence(words[i-1]) {
+			m.starts = append(m.starts, key)
+		}
+	}
+	if len(m.starts) == 0 {
+		var key markovKey
+		copy(key[:], words[:markovOrder])
+		m.starts = append(m.starts, key)
+	}
+	return m
+}
+
+// markovProse is where generated prose left off: the key for the next
+// word, and any words generated past the end of the last batch. The zero
+// value starts a new sentence.
+type markovProse struct {
+	key     markovKey
+	pending []string
+}
+
+// generate returns the next n words of the pseudo-prose p, and moves p on
+// past them. When the chain runs into a word it has never seen followed,
+// it starts a new sentence; words that opening adds beyond n are kept for
+// the next call, so the prose is the same however it's split into batches.
+func (m *MarkovChain) generate(p *markovProse, n int, rng *rand.Rand) []string {
+	words := make([]string, 0, n+markovOrder)
+	words = append(words, p.pending...)
+	p.pending = nil
+	key := &p.key
+	for len(words) < n {
+		followers := m.next[*key]
+		if len(followers) == 0 {
+			*key = m.starts[rng.Intn(len(m.starts))]
+			words = append(words, key[:]...)
+			continue
+		}
+		w := followers[rng.Intn(len(followers))]
+		words = append(words, w)
+		copy(key[:], key[1:])
+		key[markovOrder-1] = w
+	}
+	if len(words) > n {
+		p.pending = slices.Clone(words[n:])
+		words = words[:n]
+	}
+	return words
+}
+
+func endsSentence(word string) bool {
+	last := []rune(word)
+	for len(last) > 0 && !unicode.IsLetter(last[len(last)-1]) && !unicode.IsDigit(last[len(last)-1]) {
+		switch last[len(last)-1] {
+		case '.', '!', '?':
+			return true
+		}
+		last = last[:len(last)-1]
+	}
+	return false
+}
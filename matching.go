@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Matching loosens how typed text is compared with the target, for
+// practicing languages the keyboard can't easily type.
+type Matching struct {
+	IgnoreAccents bool   `json:"ignore_accents,omitempty"` // é matches e, ß matches ss
+	IgnoreCase    bool   `json:"ignore_case,omitempty"`
+	Lang          string `json:"lang,omitempty"` // language whose spellings IgnoreAccents uses
+}
+
+// letterFolds are plain spellings for letters that aren't just a base
+// letter with an accent, so stripping accents doesn't cover them.
+var letterFolds = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'þ': "th",
+	'ð': "d", 'đ': "d", 'ł': "l", 'ı': "i",
+}
+
+// langFolds are each language's own spellings for its letters, used in
+// place of stripping the accent, e.g. German ä is written ae.
+var langFolds = map[string]map[rune]string{
+	"de": {'ä': "ae", 'ö': "oe", 'ü': "ue"},
+	"da": {'æ': "ae", 'ø': "oe", 'å': "aa"},
+	"no": {'æ': "ae", 'ø': "oe", 'å': "aa"},
+	"sv": {'ä': "ae", 'ö': "oe", 'å': "aa"},
+}
+
+var matchLanguages = []string{"de", "da", "no", "sv"}
+
+func (m Matching) IsZero() bool {
+	return m == Matching{}
+}
+
+// Validate reports unknown languages, and a language set without
+// IgnoreAccents, where its spellings would never be used.
+func (m Matching) Validate() error {
+	if m.Lang != "" && langFolds[m.Lang] == nil {
+		return fmt.Errorf("unknown language %q (choose from %s)", m.Lang, strings.Join(matchLanguages, ", "))
+	}
+	if m.Lang != "" && !m.IgnoreAccents {
+		return fmt.Errorf("lang %s only applies when ignoring accents (--ignore-accents)", m.Lang)
+	}
+	return nil
+}
+
+// String describes the settings, e.g. "ignore accents (de), ignore case".
+func (m Matching) String() string {
+	var parts []string
+	if m.IgnoreAccents {
+		s := "ignore accents"
+		if m.Lang != "" {
+			s += " (" + m.Lang + ")"
+		}
+		parts = append(parts, s)
+	}
+	if m.IgnoreCase {
+		parts = append(parts, "ignore case")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fold returns the spelling of s that typed text is compared against.
+// Text is always put in composed form, so an accent typed as a separate
+// combining mark matches the same accented letter.
+func (m Matching) fold(s string) string {
+	s = norm.NFC.String(s)
+	if m.IgnoreAccents {
+		var b strings.Builder
+		for _, r := range s {
+			lower := unicode.ToLower(r)
+			rep, ok := langFolds[m.Lang][lower]
+			if !ok {
+				rep, ok = letterFolds[lower]
+			}
+			if !ok {
+				b.WriteRune(r)
+				continue
+			}
+			if lower != r {
+				rep = strings.ToUpper(rep[:1]) + rep[1:]
+			}
+			b.WriteString(rep)
+		}
+
+		// Strip the remaining accents by dropping combining marks from the
+		// decomposed text
+		var stripped strings.Builder
+		for _, r := range norm.NFD.String(b.String()) {
+			if !unicode.Is(unicode.Mn, r) {
+				stripped.WriteRune(r)
+			}
+		}
+		s = norm.NFC.String(stripped.String())
+	}
+	if m.IgnoreCase {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// equal reports whether typed text matches the target.
+func (m Matching) equal(typed, target string) bool {
+	return m.fold(typed) == m.fold(target)
+}
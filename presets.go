@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Preset is a custom test saved from the Custom form, shown on the menu.
+type Preset struct {
+	Name   string     `json:"name"`
+	Config TestConfig `json:"config"`
+}
+
+func presetsPath() string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, _ := os.UserHomeDir()
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "term-type", "presets.json")
+}
+
+func loadPresets() ([]Preset, error) {
+	data, err := os.ReadFile(presetsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var presets []Preset
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil, err
+	}
+	return presets, nil
+}
+
+func writePresets(presets []Preset) error {
+	path := presetsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(presets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// savePreset adds p to the saved presets, replacing any with the same name.
+func savePreset(p Preset) error {
+	presets, err := loadPresets()
+	if err != nil {
+		return err
+	}
+	for i := range presets {
+		if presets[i].Name == p.Name {
+			presets[i] = p
+			return writePresets(presets)
+		}
+	}
+	return writePresets(append(presets, p))
+}
+
+func deletePreset(name string) error {
+	presets, err := loadPresets()
+	if err != nil {
+		return err
+	}
+	kept := presets[:0]
+	for _, p := range presets {
+		if p.Name != name {
+			kept = append(kept, p)
+		}
+	}
+	return writePresets(kept)
+}
@@ -2,84 +2,594 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
 )
 
 type WPMSnapshot struct {
-	Elapsed float64
-	WPM     float64
-	Errors  int
+	Elapsed float64 `json:"elapsed"`
+	WPM     float64 `json:"wpm"`
+	Errors  int     `json:"errors"`
+}
+
+// Strictness limits how mistakes can be made and corrected.
+type Strictness struct {
+	// StopOn blocks the cursor on mistakes: "letter" rejects wrong keys,
+	// "word" won't leave a word until it's typed correctly.
+	StopOn string `json:"stop_on,omitempty"`
+	// Confidence restricts backspace: "on" can't reopen a finished word,
+	// "max" disables it entirely.
+	Confidence string `json:"confidence,omitempty"`
+}
+
+var (
+	stopOnModes     = []string{"letter", "word"}
+	confidenceModes = []string{"on", "max"}
+)
+
+// Validate reports unknown settings and combinations that could leave the
+// cursor stuck.
+func (st Strictness) Validate() error {
+	if st.StopOn != "" && !slices.Contains(stopOnModes, st.StopOn) {
+		return fmt.Errorf("unknown stop-on mode %q (choose from %s)", st.StopOn, strings.Join(stopOnModes, ", "))
+	}
+	if st.Confidence != "" && !slices.Contains(confidenceModes, st.Confidence) {
+		return fmt.Errorf("unknown confidence mode %q (choose from %s)", st.Confidence, strings.Join(confidenceModes, ", "))
+	}
+	if st.StopOn == "word" && st.Confidence == "max" {
+		return fmt.Errorf("stop on word needs backspace to fix mistakes, so it can't be combined with confidence max")
+	}
+	return nil
+}
+
+func (st Strictness) IsZero() bool {
+	return st == Strictness{}
+}
+
+// String describes the active settings, e.g. "stop on word, confidence max".
+func (st Strictness) String() string {
+	var parts []string
+	if st.StopOn != "" {
+		parts = append(parts, "stop on "+st.StopOn)
+	}
+	if st.Confidence != "" {
+		parts = append(parts, "confidence "+st.Confidence)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FailRules end a test early, marking it failed.
+type FailRules struct {
+	SuddenDeath bool    `json:"sudden_death,omitempty"` // fail on the first mistake
+	MinAccuracy float64 `json:"min_accuracy,omitempty"`
+	MinWPM      float64 `json:"min_wpm,omitempty"`
+	MinWPMAfter int     `json:"min_wpm_after,omitempty"` // seconds before MinWPM applies
+}
+
+// defaultMinWPMAfter is how many seconds MinWPM waits when nothing sets
+// the delay, so a slow first word doesn't fail the test.
+const defaultMinWPMAfter = 5
+
+// minAccuracyChars is how much has to be typed before MinAccuracy is
+// checked, so a slip on the first letter isn't an instant fail.
+const minAccuracyChars = 10
+
+// IsZero reports whether no rule is set. MinWPMAfter alone doesn't count,
+// since it only delays MinWPM.
+func (f FailRules) IsZero() bool {
+	return !f.SuddenDeath && f.MinAccuracy == 0 && f.MinWPM == 0
+}
+
+// Validate reports thresholds a test can't be held to: an accuracy over
+// 100% would fail every test on its first keystroke.
+func (f FailRules) Validate() error {
+	if f.MinAccuracy < 0 || f.MinAccuracy > 100 {
+		return fmt.Errorf("fail accuracy %g must be from 0 to 100", f.MinAccuracy)
+	}
+	if f.MinWPM < 0 || f.MinWPMAfter < 0 {
+		return fmt.Errorf("fail wpm and its delay can't be negative")
+	}
+	return nil
+}
+
+// String describes the active rules, e.g. "sudden death, min 60 wpm".
+func (f FailRules) String() string {
+	var parts []string
+	if f.SuddenDeath {
+		parts = append(parts, "sudden death")
+	}
+	if f.MinAccuracy > 0 {
+		parts = append(parts, fmt.Sprintf("min %.0f%% accuracy", f.MinAccuracy))
+	}
+	if f.MinWPM > 0 {
+		parts = append(parts, fmt.Sprintf("min %.0f wpm", f.MinWPM))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// TestConfig describes a test: how long it runs and how its text is made.
+type TestConfig struct {
+	TimedMode    bool       `json:"timed,omitempty"`
+	TimeLimitSec int        `json:"time,omitempty"`
+	WordCount    int        `json:"words,omitempty"`
+	Source       string     `json:"source,omitempty"` // word source for generated text
+	Filter       WordFilter `json:"filter,omitzero"`  // limits the words the "words" source picks
+	Strictness   `json:"strictness,omitzero"`
+	Fail         FailRules `json:"fail,omitzero"`
+	Matching     Matching  `json:"matching,omitzero"`
+
+	// Quote types one of the built-in quotes instead of generated words
+	Quote bool `json:"quote,omitempty"`
+
+	// Zen has no text to copy: whatever is typed is shown, and the test
+	// runs until the user ends it
+	Zen bool `json:"zen,omitempty"`
+
+	// Punctuation and Numbers mix sentence punctuation and numbers into
+	// the words source's words
+	Punctuation bool `json:"punctuation,omitempty"`
+	Numbers     bool `json:"numbers,omitempty"`
+}
+
+// String describes the config, e.g. "30s, german, punctuation".
+func (c TestConfig) String() string {
+	var parts []string
+	switch {
+	case c.Quote:
+		parts = append(parts, "quote")
+	case c.Zen:
+		parts = append(parts, "zen")
+	case c.TimedMode:
+		parts = append(parts, fmt.Sprintf("%ds", c.TimeLimitSec))
+	default:
+		parts = append(parts, fmt.Sprintf("%d words", c.WordCount))
+	}
+	if !c.Quote && !c.Zen {
+		if c.Source != "" && c.Source != "words" {
+			parts = append(parts, c.Source)
+		}
+		if c.Punctuation {
+			parts = append(parts, "punctuation")
+		}
+		if c.Numbers {
+			parts = append(parts, "numbers")
+		}
+		if !c.Filter.IsZero() {
+			parts = append(parts, c.Filter.String())
+		}
+	}
+	for _, s := range []string{c.Strictness.String(), c.Fail.String(), c.Matching.String()} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, ", ")
 }
 
 type TestState struct {
-	Target    string // the full target text
-	Input     []rune // what the user has typed so far
+	TestConfig
+
+	Target    string      // the full target text
+	Input     []rune      // what the user has typed so far
+	KeyTimes  []time.Time // when each rune in Input was typed
+	Rejected  int         // wrong keys refused by StopOn
 	StartTime time.Time
 	EndTime   time.Time
 	Started   bool
 	Finished  bool
 
-	// Mode info
-	TimedMode    bool
-	TimeLimitSec int
-	WordCount    int
-	PipedText    string // original piped text for retry
+	// Paused stops the clock until Resume; Pauses counts how many times
+	// the test was paused
+	Paused   bool
+	Pauses   int
+	pausedAt time.Time
+
+	Failed     bool
+	FailReason string
+
+	PipedText string // original piped text for retry
+	Seed      int64  // seed used to generate Target
+	Drill     *NgramDrill
+
+	// gen extends Target as a timed test goes on; nil for text that's
+	// fixed up front
+	gen *textGenerator
 
 	WPMSnapshots []WPMSnapshot
+
+	// The most recent keypress, for the on-screen keyboard
+	LastKey      rune
+	LastKeyTime  time.Time
+	LastKeyWrong bool
 }
 
-func NewTestState(target string, timedMode bool, timeLimitSec int, wordCount int) *TestState {
+func NewTestState(target string, cfg TestConfig) *TestState {
 	return &TestState{
-		Target:       target,
-		Input:        make([]rune, 0, len(target)),
-		TimedMode:    timedMode,
-		TimeLimitSec: timeLimitSec,
-		WordCount:    wordCount,
+		TestConfig: cfg,
+		Target:     target,
+		Input:      make([]rune, 0, len(target)),
+	}
+}
+
+// lookaheadWords is how many words a timed test generates at a time. More
+// are added once the cursor is within half that of the end.
+const lookaheadWords = 50
+
+// NewTimedTestState starts a test whose text comes from gen, extended as
+// the user types so it never runs out before the time does.
+func NewTimedTestState(gen *textGenerator, cfg TestConfig) *TestState {
+	s := NewTestState(gen.Next(lookaheadWords), cfg)
+	s.gen = gen
+	return s
+}
+
+// extendText generates more words once the cursor nears the end of the
+// text.
+func (s *TestState) extendText() {
+	if s.gen == nil || len(s.targetWords())-s.CurrentWord() > lookaheadWords/2 {
+		return
+	}
+	s.Target += " " + s.gen.Next(lookaheadWords)
+}
+
+// maxExtraChars caps how far typing can run past the end of a word.
+const maxExtraChars = 10
+
+// graphemes splits s into user-perceived characters, so a letter with a
+// combining accent or an emoji built from several runes counts as one.
+func graphemes(s string) []string {
+	var out []string
+	state := -1
+	for s != "" {
+		var cluster string
+		cluster, s, _, state = uniseg.FirstGraphemeClusterInString(s, state)
+		out = append(out, cluster)
+	}
+	return out
+}
+
+// charState is how one character of the text has been typed.
+type charState int
+
+const (
+	charPending charState = iota // not reached yet
+	charCorrect
+	charWrong  // typed, but not the target character
+	charMissed // skipped by pressing space early
+	charExtra  // typed past the end of the word
+)
+
+// typedWord locates one word in Input: Input[start:end], and whether a
+// space after it has moved on to the next word.
+type typedWord struct {
+	start, end int
+	done       bool
+}
+
+// targetWords splits the text into words. A zen test's text is what
+// has been typed, so every character typed is correct.
+func (s *TestState) targetWords() []string {
+	if s.Zen {
+		return strings.Split(string(s.Input), " ")
+	}
+	return strings.Split(s.Target, " ")
+}
+
+// typedWords splits Input into the words typed so far. The last one is
+// the word under the cursor, and is never done.
+func (s *TestState) typedWords() []typedWord {
+	var words []typedWord
+	start := 0
+	for i, ch := range s.Input {
+		if ch == ' ' {
+			words = append(words, typedWord{start, i, true})
+			start = i + 1
+		}
+	}
+	return append(words, typedWord{start: start, end: len(s.Input)})
+}
+
+// wordChar is how one character of a target word, or one extra character
+// typed past its end, has been typed.
+type wordChar struct {
+	text  string // the target character, or the extra one typed
+	typed string // what was typed for it
+	state charState
+}
+
+// compareWord compares the typed characters of a word with its target
+// characters, both grapheme clusters, returning one wordChar for each
+// target character followed by any extras. Each target character is
+// matched against its folded spelling, so with accents ignored ß takes two
+// keystrokes. It stays pending until all of them are typed.
+func compareWord(target, typed []string, done bool, m Matching) []wordChar {
+	type unit struct {
+		text  string
+		owner int // index of the target character it spells
+	}
+	var units []unit
+	chars := make([]wordChar, len(target))
+	for i, t := range target {
+		chars[i].text = t
+		folded := m.fold(t)
+		if folded == "" {
+			folded = t
+		}
+		for _, g := range graphemes(folded) {
+			units = append(units, unit{g, i})
+		}
+	}
+
+	typedUnits := graphemes(m.fold(strings.Join(typed, "")))
+	spelled := make([]int, len(target)) // units typed for each character
+	for j, u := range typedUnits {
+		if j >= len(units) {
+			chars = append(chars, wordChar{text: u, typed: u, state: charExtra})
+			continue
+		}
+		c := &chars[units[j].owner]
+		c.typed += u
+		spelled[units[j].owner]++
+		if u != units[j].text {
+			c.state = charWrong
+		}
+	}
+
+	total := make([]int, len(target))
+	for _, u := range units {
+		total[u.owner]++
+	}
+	for i := range target {
+		switch {
+		case chars[i].state == charWrong:
+		case spelled[i] == total[i]:
+			chars[i].state = charCorrect
+		case done:
+			chars[i].state = charMissed
+		}
+	}
+	return chars
+}
+
+// wordStates compares each typed word with its target word.
+func (s *TestState) wordStates() [][]wordChar {
+	targets := s.targetWords()
+	var out [][]wordChar
+	for i, w := range s.typedWords() {
+		if i >= len(targets) {
+			break
+		}
+		out = append(out, compareWord(graphemes(targets[i]), s.typedText(w), w.done, s.Matching))
 	}
+	return out
+}
+
+// keyFits reports whether typing ch next keeps the current word on track:
+// it continues the target word's next character, or is a space once the
+// word is complete.
+func (s *TestState) keyFits(typed, target string, ch rune) bool {
+	m := s.Matching
+	if ch == ' ' {
+		return m.equal(typed, target)
+	}
+	have := graphemes(m.fold(typed + string(ch)))
+	want := graphemes(m.fold(target))
+	// Compare decomposed, so a letter fits while its accent is still to
+	// come as a combining mark
+	n := len(have) - 1
+	return n < len(want) && strings.HasPrefix(norm.NFD.String(want[n]), norm.NFD.String(have[n]))
+}
+
+// typedText returns the characters typed for a word.
+func (s *TestState) typedText(w typedWord) []string {
+	return graphemes(string(s.Input[w.start:w.end]))
 }
 
 func (s *TestState) HandleChar(ch rune) {
-	if s.Finished {
+	if s.Finished || s.Paused {
 		return
 	}
+	now := time.Now()
 	if !s.Started {
 		s.Started = true
-		s.StartTime = time.Now()
+		s.StartTime = now
+	}
+	if s.Zen {
+		s.LastKey, s.LastKeyTime, s.LastKeyWrong = ch, now, false
+		// Any key is taken except a second space between words
+		if ch == ' ' && (len(s.Input) == 0 || s.Input[len(s.Input)-1] == ' ') {
+			return
+		}
+		s.Input = append(s.Input, ch)
+		s.KeyTimes = append(s.KeyTimes, now)
+		return
+	}
+	words := s.typedWords()
+	cur := words[len(words)-1]
+	idx := len(words) - 1
+	targets := s.targetWords()
+	if idx >= len(targets) {
+		return
+	}
+	typed := string(s.Input[cur.start:cur.end])
+	// Space does nothing before a word is started, so it isn't a
+	// mistake either
+	if typed == "" && ch == ' ' {
+		return
+	}
+	last := idx == len(targets)-1
+	fits := s.keyFits(typed, targets[idx], ch)
+	s.LastKey, s.LastKeyTime = ch, now
+	s.LastKeyWrong = !fits
+
+	switch s.StopOn {
+	case "letter":
+		if !fits {
+			s.Rejected++
+			return
+		}
+	case "word":
+		// Space can't leave a word until it's correct
+		if ch == ' ' && !fits {
+			s.Rejected++
+			return
+		}
 	}
-	// Don't allow typing past the target length
-	if len(s.Input) >= len([]rune(s.Target)) {
+
+	if ch == ' ' {
+		// Space skips to the next word, and ends the test on the last one
+		if last {
+			s.Finish()
+			return
+		}
+	} else if len(graphemes(typed)) >= len(graphemes(targets[idx]))+maxExtraChars {
 		return
 	}
+
 	s.Input = append(s.Input, ch)
+	s.KeyTimes = append(s.KeyTimes, now)
+	if ch == ' ' {
+		s.extendText()
+	}
 
-	// In word mode, finish when all characters are typed
-	if !s.TimedMode && len(s.Input) == len([]rune(s.Target)) {
+	// Finish as soon as the last word is typed correctly
+	if last && s.Matching.equal(typed+string(ch), targets[idx]) {
 		s.Finish()
 	}
 }
 
+// CheckFail ends the test as failed if it has broken one of the fail
+// rules. It's called after each keystroke and periodically while the test
+// runs, since WPM can drop without any typing.
+func (s *TestState) CheckFail() {
+	if s.Finished || !s.Started {
+		return
+	}
+	f := s.Fail
+	switch {
+	case f.SuddenDeath && s.WrongChars() > 0:
+		s.FailReason = "made a mistake"
+	case f.MinAccuracy > 0 && s.CorrectChars()+s.WrongChars() >= minAccuracyChars && s.Accuracy() < f.MinAccuracy:
+		s.FailReason = fmt.Sprintf("accuracy fell below %.0f%%", f.MinAccuracy)
+	case f.MinWPM > 0 && s.Elapsed().Seconds() >= float64(f.MinWPMAfter) && s.WPM() < f.MinWPM:
+		s.FailReason = fmt.Sprintf("speed fell below %.0f wpm", f.MinWPM)
+	default:
+		return
+	}
+	s.Failed = true
+	s.Finish()
+}
+
+// prevWordHasErrors reports whether the word before the cursor's word was
+// typed with mistakes, which is the only case backspace may return to it.
+func (s *TestState) prevWordHasErrors() bool {
+	states := s.wordStates()
+	if len(states) < 2 {
+		return false
+	}
+	for _, c := range states[len(states)-2] {
+		if c.state != charCorrect {
+			return true
+		}
+	}
+	return false
+}
+
+// canReopenWord reports whether backspace at the start of a word may go
+// back into the previous one. Zen text can always be edited.
+func (s *TestState) canReopenWord() bool {
+	return s.Confidence == "" && (s.Zen || s.prevWordHasErrors())
+}
+
 func (s *TestState) HandleBackspace() {
-	if s.Finished || len(s.Input) == 0 {
+	if s.Finished || len(s.Input) == 0 || s.Confidence == "max" {
+		return
+	}
+	if s.Input[len(s.Input)-1] == ' ' && !s.canReopenWord() {
 		return
 	}
 	s.Input = s.Input[:len(s.Input)-1]
+	s.KeyTimes = s.KeyTimes[:len(s.Input)]
 }
 
 func (s *TestState) HandleDeleteWord() {
-	if s.Finished || len(s.Input) == 0 {
+	if s.Finished || len(s.Input) == 0 || s.Confidence == "max" {
 		return
 	}
-	// Delete trailing spaces
-	for len(s.Input) > 0 && s.Input[len(s.Input)-1] == ' ' {
+	// At the start of a word, delete the previous one if it can be reopened
+	if s.Input[len(s.Input)-1] == ' ' {
+		if !s.canReopenWord() {
+			return
+		}
 		s.Input = s.Input[:len(s.Input)-1]
 	}
 	// Delete until space or empty
 	for len(s.Input) > 0 && s.Input[len(s.Input)-1] != ' ' {
 		s.Input = s.Input[:len(s.Input)-1]
 	}
+	s.KeyTimes = s.KeyTimes[:len(s.Input)]
+}
+
+// NextRune returns the rune the user should type next: the next letter of
+// the current word, a space once it's complete, or 0 at the end of the text
+// or when there is no text to follow.
+func (s *TestState) NextRune() rune {
+	if s.Zen {
+		return 0
+	}
+	words := s.typedWords()
+	idx := len(words) - 1
+	targets := s.targetWords()
+	if idx >= len(targets) {
+		return 0
+	}
+	target := []rune(s.Matching.fold(targets[idx]))
+	typed := []rune(s.Matching.fold(string(s.Input[words[idx].start:words[idx].end])))
+	if n := len(typed); n < len(target) {
+		return target[n]
+	}
+	if idx == len(targets)-1 {
+		return 0
+	}
+	return ' '
+}
+
+// CurrentWord returns the index of the word under the cursor.
+func (s *TestState) CurrentWord() int {
+	return len(s.typedWords()) - 1
+}
+
+// Pause stops the clock of a running test.
+func (s *TestState) Pause() {
+	if !s.Started || s.Finished || s.Paused {
+		return
+	}
+	s.Paused = true
+	s.pausedAt = time.Now()
+	s.Pauses++
+}
+
+// Resume restarts the clock. The test's times are moved on by the length
+// of the pause, so it counts toward neither speed nor keystroke timings.
+func (s *TestState) Resume() {
+	if !s.Paused {
+		return
+	}
+	s.Paused = false
+	d := time.Since(s.pausedAt)
+	s.StartTime = s.StartTime.Add(d)
+	for i := range s.KeyTimes {
+		s.KeyTimes[i] = s.KeyTimes[i].Add(d)
+	}
 }
 
 func (s *TestState) Finish() {
+	s.Resume()
 	if !s.Finished {
 		s.Finished = true
 		s.EndTime = time.Now()
@@ -93,6 +603,9 @@ func (s *TestState) Elapsed() time.Duration {
 	if s.Finished {
 		return s.EndTime.Sub(s.StartTime)
 	}
+	if s.Paused {
+		return s.pausedAt.Sub(s.StartTime)
+	}
 	return time.Since(s.StartTime)
 }
 
@@ -107,28 +620,72 @@ func (s *TestState) TimeRemaining() float64 {
 	return rem
 }
 
+// CorrectChars counts correctly typed characters, plus the spaces that
+// moved on to each next word.
 func (s *TestState) CorrectChars() int {
-	target := []rune(s.Target)
-	count := 0
-	for i, ch := range s.Input {
-		if i < len(target) && ch == target[i] {
-			count++
+	// Every word before the cursor's was ended by a space
+	count := s.CurrentWord()
+	for _, chars := range s.wordStates() {
+		for _, c := range chars {
+			if c.state == charCorrect {
+				count++
+			}
 		}
 	}
 	return count
 }
 
+// WrongChars counts mistakes: wrong, missed and extra characters, plus
+// keys refused by StopOn.
 func (s *TestState) WrongChars() int {
-	target := []rune(s.Target)
-	count := 0
-	for i, ch := range s.Input {
-		if i < len(target) && ch != target[i] {
-			count++
+	count := s.Rejected
+	for _, chars := range s.wordStates() {
+		for _, c := range chars {
+			if c.state == charWrong || c.state == charMissed || c.state == charExtra {
+				count++
+			}
 		}
 	}
 	return count
 }
 
+// wordStat is how one target word was typed.
+type wordStat struct {
+	word    string
+	correct int           // correctly typed characters, excluding the space after
+	typed   int           // characters typed into, excluding the space after
+	dur     time.Duration // from the keystroke before the word to its last one
+}
+
+// wordStats reports on each target word the user has typed into.
+func (s *TestState) wordStats() []wordStat {
+	targets := s.targetWords()
+	states := s.wordStates()
+	var stats []wordStat
+	for i, w := range s.typedWords() {
+		if i >= len(targets) || w.end == w.start {
+			continue
+		}
+		st := wordStat{word: targets[i]}
+		for _, c := range states[i] {
+			switch c.state {
+			case charCorrect:
+				st.correct++
+				st.typed++
+			case charWrong, charExtra:
+				st.typed++
+			}
+		}
+		from := s.StartTime
+		if w.start > 0 {
+			from = s.KeyTimes[w.start-1]
+		}
+		st.dur = s.KeyTimes[w.end-1].Sub(from)
+		stats = append(stats, st)
+	}
+	return stats
+}
+
 func (s *TestState) WPM() float64 {
 	elapsed := s.Elapsed().Minutes()
 	if elapsed == 0 {
@@ -137,20 +694,93 @@ func (s *TestState) WPM() float64 {
 	return (float64(s.CorrectChars()) / 5.0) / elapsed
 }
 
+// RawWPM is WPM counting every character typed, right or wrong.
+func (s *TestState) RawWPM() float64 {
+	elapsed := s.Elapsed().Minutes()
+	if elapsed == 0 {
+		return 0
+	}
+	typed := s.CurrentWord()
+	for _, st := range s.wordStats() {
+		typed += st.typed
+	}
+	return (float64(typed) / 5.0) / elapsed
+}
+
+// Consistency is how steady the typing speed was from word to word, from
+// 0 to 100: 100 minus the coefficient of variation of each word's speed.
+func (s *TestState) Consistency() float64 {
+	var speeds []float64
+	for i, st := range s.wordStats() {
+		// The first word's time starts at its first keystroke, so that
+		// one isn't timed
+		chars := st.typed
+		if i == 0 {
+			chars--
+		}
+		if st.dur > 0 && chars > 0 {
+			speeds = append(speeds, float64(chars)/st.dur.Seconds())
+		}
+	}
+	if len(speeds) < 2 {
+		return 100
+	}
+	var mean float64
+	for _, v := range speeds {
+		mean += v
+	}
+	mean /= float64(len(speeds))
+	var variance float64
+	for _, v := range speeds {
+		variance += (v - mean) * (v - mean)
+	}
+	cv := math.Sqrt(variance/float64(len(speeds))) / mean * 100
+	return max(0, 100-cv)
+}
+
+// KeyInterval is the average time between keystrokes.
+func (s *TestState) KeyInterval() time.Duration {
+	n := len(s.KeyTimes)
+	if n < 2 {
+		return 0
+	}
+	return s.KeyTimes[n-1].Sub(s.KeyTimes[0]) / time.Duration(n-1)
+}
+
 func (s *TestState) Accuracy() float64 {
-	total := len(s.Input)
+	correct := s.CorrectChars()
+	total := correct + s.WrongChars()
 	if total == 0 {
 		return 100
 	}
-	return float64(s.CorrectChars()) / float64(total) * 100
+	return float64(correct) / float64(total) * 100
+}
+
+// Generated reports whether Target came from a word source, and so can be
+// reproduced from Seed.
+func (s *TestState) Generated() bool {
+	return s.PipedText == "" && s.Drill == nil && !s.Zen
 }
 
 func (s *TestState) ModeString() string {
+	if s.Drill != nil {
+		return s.Drill.ModeString()
+	}
 	if s.PipedText != "" {
 		return fmt.Sprintf("pipe (%d words)", s.WordCount)
 	}
+	if s.Quote {
+		return "quote"
+	}
+	if s.Zen {
+		return "zen"
+	}
+	suffix := ""
+	if s.Source != "" && s.Source != "words" {
+		suffix = " " + s.Source
+	}
 	if s.TimedMode {
-		return fmt.Sprintf("%ds", s.TimeLimitSec)
+		return fmt.Sprintf("%ds%s", s.TimeLimitSec, suffix)
 	}
-	return fmt.Sprintf("%d words", s.WordCount)
+	return fmt.Sprintf("%d words%s", s.WordCount, suffix)
 }
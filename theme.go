@@ -215,6 +215,8 @@ func themeConfigPath() string {
 	return filepath.Join(dataDir, "term-type", "theme")
 }
 
+// loadThemePreference reads the theme file older versions saved the theme
+// in, now only used to move it into the config file.
 func loadThemePreference() string {
 	data, err := os.ReadFile(themeConfigPath())
 	if err != nil {
@@ -223,27 +225,25 @@ func loadThemePreference() string {
 	return strings.TrimSpace(string(data))
 }
 
-func saveThemePreference(name string) {
-	path := themeConfigPath()
-	os.MkdirAll(filepath.Dir(path), 0o755)
-	os.WriteFile(path, []byte(name+"\n"), 0o644)
+func saveThemePreference(name string) error {
+	return updateConfig(func(c *Config) {
+		c.Theme = name
+	})
 }
 
 func clearThemePreference() error {
-	path := themeConfigPath()
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+	if err := os.Remove(themeConfigPath()); err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	return nil
+	return updateConfig(func(c *Config) {
+		c.Theme = ""
+	})
 }
 
 func resolveThemeName(name string) string {
 	if name != "" {
 		return name
 	}
-	if saved := loadThemePreference(); saved != "" {
-		return saved
-	}
 	if detected := detectOmarchyTheme(); detected != "" {
 		return detected
 	}
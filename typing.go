@@ -3,9 +3,12 @@ package main
 import (
 	"fmt"
 	"math"
+	"strings"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/rivo/uniseg"
 )
 
 var (
@@ -19,11 +22,53 @@ var (
 	colorSubtle     tcell.Color
 )
 
+// DisplayOptions controls how the typing box draws the text.
+type DisplayOptions struct {
+	// ShowTyped draws the letter actually typed for mistakes, with the
+	// target letter on the row underneath.
+	ShowTyped bool `json:"show_typed,omitempty"`
+
+	// Blind draws mistakes like correct letters, so they're only seen on
+	// the results screen.
+	Blind bool `json:"blind,omitempty"`
+
+	// Memory hides the text that hasn't been typed yet once this many
+	// seconds have passed since the test started.
+	Memory int `json:"memory,omitempty"`
+
+	// Tape draws the text on one line that scrolls past the cursor, which
+	// stays in the middle. Otherwise the text is wrapped, showing at most
+	// Lines lines (0 for as many as fit).
+	Tape  bool `json:"tape,omitempty"`
+	Lines int  `json:"lines,omitempty"`
+
+	// Caret is how the cursor is drawn: underline (the default), block,
+	// bar or none. CaretBlink makes it blink.
+	Caret      string `json:"caret,omitempty"`
+	CaretBlink bool   `json:"caret_blink,omitempty"`
+
+	// HighlightWord shades the whole word being typed.
+	HighlightWord bool `json:"highlight_word,omitempty"`
+
+	// Sound rings the terminal bell on mistakes.
+	Sound bool `json:"sound,omitempty"`
+}
+
+var display DisplayOptions
+
+var caretStyles = []string{"underline", "block", "bar", "none"}
+
 type TypingBox struct {
 	*tview.Box
 	state    *TestState
 	onFinish func()
 	onEscape func()
+
+	// A dead key accent waiting for the letter it goes on
+	compose rune
+
+	// bell rings the terminal bell on the next draw
+	bell bool
 }
 
 func NewTypingBox(state *TestState, onFinish func(), onEscape func()) *TypingBox {
@@ -37,8 +82,90 @@ func NewTypingBox(state *TestState, onFinish func(), onEscape func()) *TypingBox
 	return tb
 }
 
+// textCell is one character (grapheme cluster) of the text as drawn: a
+// letter of a target word, an extra letter typed past its end, or the space
+// after it.
+type textCell struct {
+	text  string
+	typed string // what was typed instead, for charWrong
+	state charState
+	space bool
+	word  int // index of the word the cell belongs to
+	width int // columns the character takes on screen
+}
+
+// layoutCells lays out the target text with the user's typing applied, and
+// returns the index of the cell under the cursor.
+func layoutCells(s *TestState) ([]textCell, int) {
+	targets := s.targetWords()
+	typed := s.typedWords()
+	states := s.wordStates()
+	current := len(typed) - 1
+
+	var cells []textCell
+	cursor := -1
+	for wi, word := range targets {
+		target := graphemes(word)
+		if wi >= len(states) {
+			for _, g := range target {
+				cells = append(cells, textCell{text: g, word: wi})
+			}
+		} else {
+			for _, c := range states[wi] {
+				// The cursor is on the first character not yet fully typed
+				if wi == current && cursor < 0 && c.state == charPending {
+					cursor = len(cells)
+				}
+				cells = append(cells, textCell{text: c.text, typed: c.typed, state: c.state, word: wi})
+			}
+			if wi == current && cursor < 0 {
+				cursor = len(cells)
+			}
+		}
+
+		if wi < len(targets)-1 {
+			st := charPending
+			if wi < current {
+				st = charCorrect
+			}
+			cells = append(cells, textCell{text: " ", state: st, space: true, word: wi})
+		}
+	}
+	if s.Zen {
+		// Zen text ends at the cursor, so give it a blank cell to sit on
+		cells = append(cells, textCell{text: " ", space: true, word: current})
+	}
+	for i := range cells {
+		// Even a stray combining mark gets a column of its own
+		cells[i].width = max(uniseg.StringWidth(cells[i].text), 1)
+	}
+	if cursor < 0 {
+		cursor = len(cells)
+	}
+	return cells, cursor
+}
+
+// cellStyle is how a cell of the text is drawn, other than the cursor.
+func cellStyle(c textCell, blind bool) tcell.Style {
+	style := tcell.StyleDefault.Background(colorBackground)
+	switch {
+	case c.state == charPending:
+		return style.Foreground(colorPending)
+	case c.state == charCorrect || blind:
+		return style.Foreground(colorCorrect)
+	case c.state == charMissed:
+		return style.Foreground(colorWrongFg).Underline(true)
+	default:
+		return style.Foreground(colorWrongFg).Background(colorWrongBg)
+	}
+}
+
 func (t *TypingBox) Draw(screen tcell.Screen) {
 	t.Box.DrawForSubclass(screen, t)
+	if t.bell {
+		t.bell = false
+		screen.Beep()
+	}
 	x, y, width, height := t.GetInnerRect()
 	if width <= 0 || height <= 0 {
 		return
@@ -55,30 +182,105 @@ func (t *TypingBox) Draw(screen tcell.Screen) {
 	x += pad
 	width -= pad * 2
 
-	target := []rune(t.state.Target)
-	input := t.state.Input
-	cursorPos := len(input)
+	// A paused test hides its text, so the pause can't be used to read
+	// ahead
+	if t.state.Paused {
+		msgY := y + height/2 - 1
+		title := "paused"
+		help := "[ctrl+p] resume  [esc] menu"
+		drawString(screen, x+(width-len(title))/2, msgY, title,
+			tcell.StyleDefault.Background(colorBackground).Foreground(colorAccent).Bold(true))
+		drawString(screen, x+(width-len(help))/2, msgY+2, help,
+			tcell.StyleDefault.Background(colorBackground).Foreground(colorSubtle))
+		return
+	}
+
+	cells, cursorPos := layoutCells(t.state)
+
+	// Column of each cell from the start of the text, for wide characters
+	cols := make([]int, len(cells)+1)
+	for ci, c := range cells {
+		cols[ci+1] = cols[ci] + c.width
+	}
+
+	// Reserve top line for timer/info
+	infoY := y
+	textStartY := y + 2
+
+	// Draw timer/info line
+	var info string
+	switch {
+	case t.state.Zen && !t.state.Started:
+		info = "type anything - ctrl+d to finish"
+	case t.state.Zen:
+		info = fmt.Sprintf("%.1f", t.state.Elapsed().Seconds())
+	case t.state.TimedMode:
+		remaining := t.state.TimeRemaining()
+		info = fmt.Sprintf("%.1f", remaining)
+	default:
+		// Show word progress
+		wordsTyped := t.state.CurrentWord()
+		if t.state.Finished {
+			wordsTyped = t.state.WordCount
+		}
+		info = fmt.Sprintf("%d/%d", wordsTyped, t.state.WordCount)
+	}
+	infoStyle := tcell.StyleDefault.Background(colorBackground).Foreground(colorAccent).Bold(true)
+	drawString(screen, x+(width-uniseg.StringWidth(info))/2, infoY, info, infoStyle)
+
+	hidden := display.Memory > 0 && t.state.Started &&
+		t.state.Elapsed() >= time.Duration(display.Memory)*time.Second
+
+	caret := cursorPos
+	currentWord := -1
+	if display.HighlightWord && cursorPos < len(cells) {
+		currentWord = cells[cursorPos].word
+	}
+	if display.Caret == "bar" {
+		style := tcell.CursorStyleSteadyBar
+		if display.CaretBlink {
+			style = tcell.CursorStyleBlinkingBar
+		}
+		screen.SetCursorStyle(style, colorCursor)
+	}
+
+	if display.Tape {
+		// Keep the caret in the middle and scroll the text past it
+		center := x + width/2
+		for ci, c := range cells {
+			cx := center + cols[ci] - cols[caret]
+			if cx >= x && cx+c.width <= x+width {
+				t.drawCell(screen, cx, textStartY, c, ci == caret, c.word == currentWord, hidden)
+			}
+		}
+		t.drawLiveWPM(screen, x, y, width, height)
+		return
+	}
 
-	// Word-wrap: break target text into lines that fit within width
+	// Word-wrap: break the text into lines that fit within width
 	type lineInfo struct {
-		start int // index into target runes
+		start int // index into cells
 		end   int // exclusive
 	}
 	var lines []lineInfo
 
 	i := 0
-	for i < len(target) {
+	for i < len(cells) {
 		lineStart := i
 		lastSpace := -1
 		col := 0
-		for i < len(target) && col < width {
-			if target[i] == ' ' {
+		for i < len(cells) && col+cells[i].width <= width {
+			if cells[i].space {
 				lastSpace = i
 			}
-			col++
+			col += cells[i].width
+			i++
+		}
+		if i == lineStart {
+			// A character wider than the box still needs a line
 			i++
 		}
-		if i < len(target) && lastSpace > lineStart {
+		if i < len(cells) && lastSpace > lineStart {
 			// Wrap at last space
 			lines = append(lines, lineInfo{lineStart, lastSpace + 1})
 			i = lastSpace + 1
@@ -104,11 +306,16 @@ func (t *TypingBox) Draw(screen tcell.Screen) {
 	}
 
 	// Show a few lines of context, centered around cursor line
-	// Reserve top line for timer/info
-	infoY := y
-	textStartY := y + 2
-	maxTextLines := height - 3
+	// Showing typed letters leaves a row under each line for the target
+	lineHeight := 1
+	if display.ShowTyped {
+		lineHeight = 2
+	}
+	maxTextLines := (height - 3) / lineHeight
 
+	if display.Lines > 0 && maxTextLines > display.Lines {
+		maxTextLines = display.Lines
+	}
 	if maxTextLines < 1 {
 		maxTextLines = 1
 	}
@@ -122,76 +329,68 @@ func (t *TypingBox) Draw(screen tcell.Screen) {
 		}
 	}
 
-	// Draw timer/info line
-	var info string
-	if t.state.TimedMode {
-		remaining := t.state.TimeRemaining()
-		info = fmt.Sprintf("%.1f", remaining)
-	} else {
-		// Show word progress
-		wordsTyped := 0
-		for _, ch := range input {
-			if ch == ' ' {
-				wordsTyped++
-			}
-		}
-		if t.state.Finished {
-			wordsTyped = t.state.WordCount
-		}
-		info = fmt.Sprintf("%d/%d", wordsTyped, t.state.WordCount)
-	}
-	infoStyle := tcell.StyleDefault.Background(colorBackground).Foreground(colorAccent).Bold(true)
-	infoX := x + (width-len(info))/2
-	for ci, ch := range info {
-		screen.SetContent(infoX+ci, infoY, ch, nil, infoStyle)
-	}
-
 	// Draw each visible line
 	for li := scrollOffset; li < len(lines) && li-scrollOffset < maxTextLines; li++ {
 		ln := lines[li]
-		lineY := textStartY + (li - scrollOffset)
+		lineY := textStartY + (li-scrollOffset)*lineHeight
 
 		// Center the line
-		lineLen := ln.end - ln.start
+		lineLen := cols[ln.end] - cols[ln.start]
 		lineX := x + (width-lineLen)/2
 
 		for ci := ln.start; ci < ln.end; ci++ {
-			ch := target[ci]
-			style := tcell.StyleDefault.Background(colorBackground)
-
-			if ci < cursorPos {
-				// Already typed
-				if ci < len(input) && input[ci] == target[ci] {
-					style = style.Foreground(colorCorrect)
-				} else {
-					style = style.Foreground(colorWrongFg).Background(colorWrongBg)
-					// Show what user typed if it's a printable char, otherwise show target
-					if ci < len(input) && input[ci] != target[ci] {
-						ch = target[ci] // Keep target char visible but colored wrong
-					}
-				}
-			} else if ci == cursorPos {
-				// Cursor position
-				style = style.Foreground(colorCursor).Underline(true)
-			} else {
-				// Pending
-				style = style.Foreground(colorPending)
-			}
+			c := cells[ci]
+			t.drawCell(screen, lineX+cols[ci]-cols[ln.start], lineY, c, ci == caret, c.word == currentWord, hidden)
+		}
+	}
+
+	t.drawLiveWPM(screen, x, y, width, height)
+}
 
-			screen.SetContent(lineX+(ci-ln.start), lineY, ch, nil, style)
+// drawCell draws one cell of the text at (x, y), and the target letter
+// under it when showing typed letters for a mistake. Hidden blanks the text
+// that hasn't been typed yet.
+func (t *TypingBox) drawCell(screen tcell.Screen, x, y int, c textCell, caret, highlight, hidden bool) {
+	text := c.text
+	style := cellStyle(c, display.Blind)
+	if _, bg, _ := style.Decompose(); highlight && !c.space && bg == colorBackground {
+		style = style.Background(blendColors(colorBackground, colorCursor, 0.15))
+	}
+	if caret && t.compose != 0 {
+		// Show the accent being composed in place of the next letter
+		text = string(t.compose)
+		style = tcell.StyleDefault.Background(colorBackground).Foreground(colorSubtle).Underline(true)
+	} else if caret {
+		base := tcell.StyleDefault.Background(colorBackground)
+		switch display.Caret {
+		case "block":
+			style = base.Foreground(colorBackground).Background(colorCursor).Blink(display.CaretBlink)
+		case "bar":
+			screen.ShowCursor(x, y)
+		case "none":
+		default:
+			style = base.Foreground(colorCursor).Underline(true).Blink(display.CaretBlink)
 		}
 	}
 
-	// Draw WPM at bottom if test is in progress and user has started typing
+	if hidden && c.state == charPending {
+		text = strings.Repeat(" ", c.width)
+	}
+	if display.ShowTyped && !display.Blind && c.state == charWrong {
+		text = c.typed
+		hintStyle := tcell.StyleDefault.Background(colorBackground).Foreground(colorSubtle)
+		drawString(screen, x, y+1, c.text, hintStyle)
+	}
+	drawString(screen, x, y, text, style)
+}
+
+// drawLiveWPM draws the WPM at the bottom once the user has started typing.
+func (t *TypingBox) drawLiveWPM(screen tcell.Screen, x, y, width, height int) {
 	if t.state.Started && !t.state.Finished {
 		wpm := t.state.WPM()
 		wpmStr := fmt.Sprintf("%.0f wpm", math.Round(wpm))
 		wpmStyle := tcell.StyleDefault.Background(colorBackground).Foreground(colorSubtle)
-		wpmX := x + (width-len(wpmStr))/2
-		wpmY := y + height - 1
-		for ci, ch := range wpmStr {
-			screen.SetContent(wpmX+ci, wpmY, ch, nil, wpmStyle)
-		}
+		drawString(screen, x+(width-uniseg.StringWidth(wpmStr))/2, y+height-1, wpmStr, wpmStyle)
 	}
 }
 
@@ -200,23 +399,80 @@ func (t *TypingBox) InputHandler() func(event *tcell.EventKey, setFocus func(p t
 		if t.state.Finished {
 			return
 		}
+		if t.state.Paused {
+			switch event.Key() {
+			case tcell.KeyCtrlP:
+				t.state.Resume()
+			case tcell.KeyEscape:
+				t.onEscape()
+			}
+			return
+		}
 
 		switch event.Key() {
 		case tcell.KeyEscape:
 			t.onEscape()
 			return
 		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if t.compose != 0 {
+				t.compose = 0
+				return
+			}
 			t.state.HandleBackspace()
 			return
 		case tcell.KeyCtrlW:
 			t.state.HandleDeleteWord()
 			return
-		case tcell.KeyRune:
-			t.state.HandleChar(event.Rune())
-			if t.state.Finished {
+		case tcell.KeyCtrlT:
+			display.ShowTyped = !display.ShowTyped
+			return
+		case tcell.KeyCtrlP:
+			t.state.Pause()
+			return
+		case tcell.KeyCtrlD:
+			// Zen tests run until they're ended
+			if t.state.Zen && t.state.Started {
+				t.state.Finish()
 				t.onFinish()
 			}
 			return
+		case tcell.KeyRune:
+			ch := event.Rune()
+			if r, ok := keyRemap[ch]; ok {
+				ch = r
+			}
+
+			// Hold a dead key accent until the next key, unless the
+			// accent itself is what comes next in the text. Zen has no
+			// text to go by, so its keys always type themselves
+			if accent := t.compose; accent != 0 {
+				t.compose = 0
+				for _, r := range composeDeadKey(accent, ch) {
+					t.typeRune(r)
+				}
+				return
+			}
+			if _, ok := deadKeys[ch]; ok && composeKeys && !t.state.Zen && t.state.NextRune() != ch {
+				t.compose = ch
+				return
+			}
+			t.typeRune(ch)
+			return
 		}
 	})
 }
+
+// typeRune passes one typed character to the test, ending it if that
+// finished or failed it.
+func (t *TypingBox) typeRune(ch rune) {
+	if t.state.Finished {
+		return
+	}
+	t.state.HandleChar(ch)
+	// A bell on each mistake would give away what blind mode hides
+	t.bell = display.Sound && !display.Blind && t.state.LastKeyWrong
+	t.state.CheckFail()
+	if t.state.Finished {
+		t.onFinish()
+	}
+}
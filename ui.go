@@ -1,40 +1,84 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
-func buildMenu(app *tview.Application, pages *tview.Pages, startTest func(timedMode bool, timeLimitSec int, wordCount int), showHistory func(), showThemes func()) *tview.Flex {
-	list := tview.NewList().
-		AddItem("Time 15s", "Timed mode - 15 seconds", '1', func() {
-			startTest(true, 15, 50)
-		}).
-		AddItem("Time 30s", "Timed mode - 30 seconds", '2', func() {
-			startTest(true, 30, 100)
-		}).
-		AddItem("Time 60s", "Timed mode - 60 seconds", '3', func() {
-			startTest(true, 60, 200)
-		}).
-		AddItem("Words 10", "Type 10 words", '4', func() {
-			startTest(false, 0, 10)
-		}).
-		AddItem("Words 25", "Type 25 words", '5', func() {
-			startTest(false, 0, 25)
+func buildMenu(app *tview.Application, pages *tview.Pages, items []MenuItem, startItem func(MenuItem), startDrill func(), showCustom func(), showHistory func(), showThemes func(), showSettings func(), source string, cycleSource func() string, presets []Preset, startSaved func(TestConfig), removeSaved func(name string)) *tview.Flex {
+	list := tview.NewList()
+	used := map[rune]bool{}
+	for _, item := range items {
+		description := item.Description
+		if description == "" {
+			description = item.Test.String()
+		}
+		list.AddItem(item.Name, description, item.key(), func() {
+			startItem(item)
+		})
+		used[item.key()] = true
+	}
+
+	// Saved presets follow the configured tests, taking the free digits
+	firstSaved := list.GetItemCount()
+	next := '1'
+	for _, p := range presets {
+		for next <= '9' && used[next] {
+			next++
+		}
+		var shortcut rune
+		if next <= '9' {
+			shortcut = next
+			next++
+		}
+		cfg := p.Config
+		list.AddItem(p.Name, cfg.String(), shortcut, func() {
+			startSaved(cfg)
+		})
+	}
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		idx := list.GetCurrentItem()
+		if event.Key() == tcell.KeyDelete && idx >= firstSaved && idx < firstSaved+len(presets) {
+			removeSaved(presets[idx-firstSaved].Name)
+			return nil
+		}
+		return event
+	})
+
+	list.
+		AddItem("Custom", "Choose length and word filters", 'c', func() {
+			showCustom()
 		}).
-		AddItem("Words 50", "Type 50 words", '6', func() {
-			startTest(false, 0, 50)
+		AddItem("N-gram drill", "Practice common letter groups", 'n', func() {
+			startDrill()
 		}).
+		AddItem("Zen", "Type freely with no text to copy", 'z', func() {
+			startSaved(TestConfig{Zen: true})
+		})
+
+	sourceItem := list.GetItemCount()
+	list.AddItem("Source: "+source, "Where generated words come from", 's', func() {
+		list.SetItemText(sourceItem, "Source: "+cycleSource(), "Where generated words come from")
+	})
+
+	list.
 		AddItem("History", "View past results", 'h', func() {
 			showHistory()
 		}).
 		AddItem("Theme", "Change color theme", 't', func() {
 			showThemes()
 		}).
+		AddItem("Settings", "Change defaults saved in the config file", 'o', func() {
+			showSettings()
+		}).
 		AddItem("Quit", "Exit the application", 'q', func() {
 			app.Stop()
 		})
@@ -67,8 +111,16 @@ func buildMenu(app *tview.Application, pages *tview.Pages, startTest func(timedM
 			AddItem(nil, 0, 1, false).
 			AddItem(list, 40, 0, true).
 			AddItem(nil, 0, 1, false),
-			0, 1, true).
-		AddItem(nil, 0, 1, false)
+			0, 1, true)
+	if len(presets) > 0 {
+		help := tview.NewTextView().
+			SetText("[del] remove saved preset").
+			SetTextAlign(tview.AlignCenter).
+			SetTextColor(colorSubtle)
+		help.SetBackgroundColor(colorBackground)
+		flex.AddItem(help, 1, 0, false)
+	}
+	flex.AddItem(nil, 0, 1, false)
 	flex.SetBackgroundColor(colorBackground)
 
 	return flex
@@ -92,26 +144,111 @@ func buildResults(app *tview.Application, pages *tview.Pages, state *TestState,
 		SetTextColor(colorSubtle)
 	wpmLabel.SetBackgroundColor(colorBackground)
 
+	// Zen has nothing to be accurate to, so it reports keystroke timing
+	accText, accName := fmt.Sprintf("%.1f%%", acc), "accuracy"
+	if state.Zen {
+		accText, accName = fmt.Sprintf("%d", state.KeyInterval().Milliseconds()), "ms per key"
+	}
 	accView := tview.NewTextView().
-		SetText(fmt.Sprintf("%.1f%%", acc)).
+		SetText(accText).
 		SetTextAlign(tview.AlignCenter).
 		SetTextColor(colorAccent)
 	accView.SetBackgroundColor(colorBackground)
 
 	accLabel := tview.NewTextView().
-		SetText("accuracy").
+		SetText(accName).
 		SetTextAlign(tview.AlignCenter).
 		SetTextColor(colorSubtle)
 	accLabel.SetBackgroundColor(colorBackground)
 
+	stats := fmt.Sprintf("%d correct  /  %d wrong  /  %s", correct, wrong, state.ModeString())
+	if state.Zen {
+		stats = fmt.Sprintf("%d keys  /  %.1fs  /  zen", len(state.Input), state.Elapsed().Seconds())
+	}
+	if state.Pauses > 0 {
+		stats += "  /  paused"
+	}
+	if !state.Strictness.IsZero() {
+		stats += "  /  " + state.Strictness.String()
+	}
+	if !state.Matching.IsZero() {
+		stats += "  /  " + state.Matching.String()
+	}
+	if !state.Fail.IsZero() {
+		stats += "  /  " + state.Fail.String()
+	}
+	if state.Generated() {
+		if !state.Filter.IsZero() {
+			stats += "  /  " + state.Filter.String()
+		}
+		if state.Punctuation {
+			stats += "  /  punctuation"
+		}
+		if state.Numbers {
+			stats += "  /  numbers"
+		}
+		stats += fmt.Sprintf("  /  seed %d", state.Seed)
+	}
 	statsView := tview.NewTextView().
-		SetText(fmt.Sprintf("%d correct  /  %d wrong  /  %s", correct, wrong, state.ModeString())).
+		SetText(stats).
 		SetTextAlign(tview.AlignCenter).
 		SetTextColor(colorSubtle)
 	statsView.SetBackgroundColor(colorBackground)
 
+	failView := tview.NewTextView().
+		SetTextAlign(tview.AlignCenter).
+		SetTextColor(colorWrongFg)
+	failView.SetBackgroundColor(colorBackground)
+	failHeight := 0
+	if state.Failed {
+		failView.SetText("failed - " + state.FailReason)
+		failHeight = 2
+	}
+
+	// Drill tests report each n-gram and whether the set was passed
+	drillView := tview.NewTextView().
+		SetTextAlign(tview.AlignCenter).
+		SetTextColor(colorSubtle)
+	drillView.SetBackgroundColor(colorBackground)
+	drillHeight := 0
+	retryHelp := "[enter] retry"
+	if d := state.Drill; d != nil {
+		var parts []string
+		for _, r := range ngramResults(state) {
+			parts = append(parts, fmt.Sprintf("%s %.0f%% %.0f wpm", r.Ngram, r.Accuracy, math.Round(r.WPM)))
+		}
+		verdict := fmt.Sprintf("needs %.0f%% accuracy and %.0f wpm to advance", d.MinAccuracy, d.MinWPM)
+		if d.Passed(state) {
+			verdict = "passed"
+			retryHelp = "[enter] next set"
+			if d.Set == len(d.Sets)-1 {
+				verdict = "passed - all sets complete"
+				retryHelp = "[enter] start over"
+			}
+		}
+		drillView.SetText(strings.Join(parts, "   ") + "\n" + verdict)
+		drillHeight = 2
+	}
+
+	// Blind mode hid mistakes while typing, so show them here
+	reviewView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true).
+		SetTextAlign(tview.AlignCenter)
+	reviewView.SetBackgroundColor(colorBackground)
+	reviewSize := 0
+	if display.Blind {
+		reviewView.SetText(reviewText(state))
+		reviewSize = 1
+	}
+	reviewWrapper := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(reviewView, 60, 0, false).
+		AddItem(nil, 0, 1, false)
+	reviewWrapper.SetBackgroundColor(colorBackground)
+
 	helpView := tview.NewTextView().
-		SetText("[enter] retry  [tab] menu  [h] history  [q] quit").
+		SetText(retryHelp + "  [tab] menu  [h] history  [q] quit").
 		SetTextAlign(tview.AlignCenter).
 		SetTextColor(colorSubtle)
 	helpView.SetBackgroundColor(colorBackground)
@@ -128,6 +265,7 @@ func buildResults(app *tview.Application, pages *tview.Pages, state *TestState,
 
 	flex := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(nil, 0, 1, false).
+		AddItem(failView, failHeight, 0, false).
 		AddItem(wpmView, 2, 0, false).
 		AddItem(wpmLabel, 1, 0, false).
 		AddItem(nil, 1, 0, false).
@@ -136,6 +274,8 @@ func buildResults(app *tview.Application, pages *tview.Pages, state *TestState,
 		AddItem(nil, 1, 0, false).
 		AddItem(statsView, 1, 0, false).
 		AddItem(nil, 1, 0, false).
+		AddItem(drillView, drillHeight, 0, false).
+		AddItem(reviewWrapper, 0, reviewSize, false).
 		AddItem(graphWrapper, 0, 2, false).
 		AddItem(nil, 1, 0, false).
 		AddItem(helpView, 1, 0, true).
@@ -166,6 +306,22 @@ func buildResults(app *tview.Application, pages *tview.Pages, state *TestState,
 	return flex
 }
 
+// reviewText returns the typed part of a test's text, colored as the typing
+// box colors it, with tview color tags.
+func reviewText(state *TestState) string {
+	cells, cursor := layoutCells(state)
+	var b strings.Builder
+	for _, c := range cells[:cursor] {
+		fg, bg, _ := cellStyle(c, false).Decompose()
+		attrs := ""
+		if c.state == charMissed {
+			attrs = "u"
+		}
+		fmt.Fprintf(&b, "[%s:%s:%s]%s", fg.CSS(), bg.CSS(), attrs, tview.Escape(c.text))
+	}
+	return b.String()
+}
+
 func buildHistory(app *tview.Application, pages *tview.Pages, onClear ...func()) *tview.Flex {
 	table := tview.NewTable().
 		SetFixed(1, 0).
@@ -192,13 +348,24 @@ func buildHistory(app *tview.Application, pages *tview.Pages, onClear ...func())
 
 	for i, r := range results {
 		row := i + 1
+		mode, modeColor := r.Mode, colorCorrect
+		if r.Paused {
+			mode += " (paused)"
+		}
+		if r.Failed {
+			mode, modeColor = mode+" (failed)", colorWrongFg
+		}
 		table.SetCell(row, 0, tview.NewTableCell(r.Date.Format(time.DateTime)).
 			SetTextColor(colorCorrect).SetAlign(tview.AlignCenter).SetExpansion(1))
-		table.SetCell(row, 1, tview.NewTableCell(r.Mode).
-			SetTextColor(colorCorrect).SetAlign(tview.AlignCenter).SetExpansion(1))
+		table.SetCell(row, 1, tview.NewTableCell(mode).
+			SetTextColor(modeColor).SetAlign(tview.AlignCenter).SetExpansion(1))
 		table.SetCell(row, 2, tview.NewTableCell(fmt.Sprintf("%.0f", r.WPM)).
 			SetTextColor(colorCorrect).SetAlign(tview.AlignCenter).SetExpansion(1))
-		table.SetCell(row, 3, tview.NewTableCell(fmt.Sprintf("%.1f%%", r.Accuracy)).
+		accuracy := fmt.Sprintf("%.1f%%", r.Accuracy)
+		if r.KeyMS > 0 {
+			accuracy = fmt.Sprintf("%.0f ms/key", r.KeyMS)
+		}
+		table.SetCell(row, 3, tview.NewTableCell(accuracy).
 			SetTextColor(colorCorrect).SetAlign(tview.AlignCenter).SetExpansion(1))
 	}
 
@@ -313,3 +480,410 @@ func buildThemePicker(app *tview.Application, pages *tview.Pages, onSelect func(
 
 	return flex
 }
+
+// customModes are the Custom form's Mode options, in order.
+var customModes = []string{"time", "words", "quote", "zen"}
+
+func buildCustomForm(app *tview.Application, pages *tview.Pages, defaults TestConfig, onStart func(TestConfig), onSave func(Preset)) *tview.Flex {
+	modeIdx, length := 1, defaults.WordCount
+	switch {
+	case defaults.Quote:
+		modeIdx, length = 2, 0
+	case defaults.Zen:
+		modeIdx, length = 3, 0
+	case defaults.TimedMode:
+		modeIdx, length = 0, defaults.TimeLimitSec
+	}
+	// Blank means no limit for the optional number fields
+	optional := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	f := defaults.Filter
+	layoutIdx := 0
+	for i, name := range layoutOrder {
+		if name == f.Layout {
+			layoutIdx = i
+		}
+	}
+	languageIdx := 0
+	for i, name := range languages {
+		if name == f.language() {
+			languageIdx = i
+		}
+	}
+
+	form := tview.NewForm().
+		AddDropDown("Mode", customModes, modeIdx, nil).
+		AddInputField("Length", optional(length), 8, tview.InputFieldInteger, nil).
+		AddDropDown("Language", languages, languageIdx, nil).
+		AddCheckbox("Punctuation", defaults.Punctuation, nil).
+		AddCheckbox("Numbers", defaults.Numbers, nil).
+		AddInputField("Min word length", optional(f.MinLen), 8, tview.InputFieldInteger, nil).
+		AddInputField("Max word length", optional(f.MaxLen), 8, tview.InputFieldInteger, nil).
+		AddInputField("Top N words", optional(f.Top), 8, tview.InputFieldInteger, nil).
+		AddInputField("Only letters", f.Only, 30, nil, nil).
+		AddInputField("Exclude letters", f.Exclude, 30, nil, nil).
+		AddInputField("Require letters", f.Require, 30, nil, nil).
+		AddDropDown("Layout", layoutOrder, layoutIdx, nil).
+		AddInputField("Keys", f.Keys, 30, nil, nil).
+		AddDropDown("Stop on", append([]string{"off"}, stopOnModes...), optionIndex(stopOnModes, defaults.StopOn), nil).
+		AddDropDown("Confidence", append([]string{"off"}, confidenceModes...), optionIndex(confidenceModes, defaults.Confidence), nil).
+		AddCheckbox("Sudden death", defaults.Fail.SuddenDeath, nil).
+		AddInputField("Fail below accuracy", optionalNumber(defaults.Fail.MinAccuracy), 8, tview.InputFieldFloat, nil).
+		AddInputField("Fail below wpm", optionalNumber(defaults.Fail.MinWPM), 8, tview.InputFieldFloat, nil).
+		AddCheckbox("Ignore accents", defaults.Matching.IgnoreAccents, nil).
+		AddDropDown("Accent rules", append([]string{"off"}, matchLanguages...), optionIndex(matchLanguages, defaults.Matching.Lang), nil).
+		AddCheckbox("Ignore case", defaults.Matching.IgnoreCase, nil).
+		AddInputField("Save as", "", 30, nil, nil)
+	// Keep every field on screen at once
+	form.SetItemPadding(0)
+
+	keysField := form.GetFormItemByLabel("Keys").(*tview.InputField)
+	keysField.SetPlaceholder("e.g. home+top or left")
+	keysField.SetPlaceholderTextColor(colorSubtle)
+	nameField := form.GetFormItemByLabel("Save as").(*tview.InputField)
+	nameField.SetPlaceholder("preset name, for the menu")
+	nameField.SetPlaceholderTextColor(colorSubtle)
+
+	text := func(label string) string {
+		return form.GetFormItemByLabel(label).(*tview.InputField).GetText()
+	}
+	number := func(label string) int {
+		n, _ := strconv.Atoi(text(label))
+		return n
+	}
+	float := func(label string) float64 {
+		n, _ := strconv.ParseFloat(text(label), 64)
+		return n
+	}
+
+	checked := func(label string) bool {
+		return form.GetFormItemByLabel(label).(*tview.Checkbox).IsChecked()
+	}
+
+	// formConfig builds the test described by the form
+	formConfig := func() (TestConfig, error) {
+		cfg := defaults
+		_, language := form.GetFormItemByLabel("Language").(*tview.DropDown).GetCurrentOption()
+		if language == "english" {
+			language = ""
+		}
+		cfg.Punctuation = checked("Punctuation")
+		cfg.Numbers = checked("Numbers")
+		cfg.Filter = WordFilter{
+			Language: language,
+			MinLen:   number("Min word length"),
+			MaxLen:   number("Max word length"),
+			Top:      number("Top N words"),
+			Only:     text("Only letters"),
+			Exclude:  text("Exclude letters"),
+			Require:  text("Require letters"),
+			Keys:     text("Keys"),
+		}
+		if cfg.Filter.Keys != "" {
+			_, cfg.Filter.Layout = form.GetFormItemByLabel("Layout").(*tview.DropDown).GetCurrentOption()
+		}
+		cfg.Strictness = Strictness{
+			StopOn:     offOption(form, "Stop on"),
+			Confidence: offOption(form, "Confidence"),
+		}
+		cfg.Fail.SuddenDeath = checked("Sudden death")
+		cfg.Fail.MinAccuracy = float("Fail below accuracy")
+		cfg.Fail.MinWPM = float("Fail below wpm")
+		cfg.Matching = Matching{
+			IgnoreAccents: checked("Ignore accents"),
+			IgnoreCase:    checked("Ignore case"),
+			Lang:          offOption(form, "Accent rules"),
+		}
+		n := number("Length")
+		_, mode := form.GetFormItemByLabel("Mode").(*tview.DropDown).GetCurrentOption()
+		cfg.TimedMode, cfg.TimeLimitSec, cfg.WordCount, cfg.Quote, cfg.Zen = false, 0, 0, false, false
+		switch mode {
+		case "time":
+			cfg.TimedMode, cfg.TimeLimitSec = true, n
+		case "words":
+			cfg.WordCount = n
+		case "quote":
+			cfg.Quote = true
+		case "zen":
+			cfg.Zen = true
+		}
+
+		if n <= 0 && !cfg.Quote && !cfg.Zen {
+			return cfg, errors.New("length must be a positive number")
+		}
+		if err := cfg.Filter.Validate(); err != nil {
+			return cfg, err
+		}
+		if err := cfg.Fail.Validate(); err != nil {
+			return cfg, err
+		}
+		if err := cfg.Matching.Validate(); err != nil {
+			return cfg, err
+		}
+		return cfg, cfg.Strictness.Validate()
+	}
+
+	form.AddButton("Start", func() {
+		cfg, err := formConfig()
+		if err != nil {
+			showMessage(pages, "Can't start test", err.Error())
+			return
+		}
+		onStart(cfg)
+	})
+	form.AddButton("Save", func() {
+		cfg, err := formConfig()
+		if err == nil && strings.TrimSpace(nameField.GetText()) == "" {
+			err = errors.New("enter a name in Save as to save this test as a preset")
+		}
+		if err != nil {
+			showMessage(pages, "Can't save preset", err.Error())
+			return
+		}
+		onSave(Preset{Name: strings.TrimSpace(nameField.GetText()), Config: cfg})
+	})
+	form.AddButton("Cancel", func() {
+		pages.SwitchToPage("menu")
+	})
+	form.SetCancelFunc(func() {
+		pages.SwitchToPage("menu")
+	})
+
+	form.SetBackgroundColor(colorBackground)
+	form.SetLabelColor(colorAccent)
+	form.SetFieldBackgroundColor(blendColors(colorBackground, colorCorrect, 0.1))
+	form.SetFieldTextColor(colorCorrect)
+	form.SetButtonBackgroundColor(colorAccent)
+	form.SetButtonTextColor(colorBackground)
+
+	title := tview.NewTextView().
+		SetText("Custom test").
+		SetTextAlign(tview.AlignCenter).
+		SetTextColor(colorAccent)
+	title.SetBackgroundColor(colorBackground)
+
+	helpView := tview.NewTextView().
+		SetText("[tab] next field  [esc] back to menu").
+		SetTextAlign(tview.AlignCenter).
+		SetTextColor(colorSubtle)
+	helpView.SetBackgroundColor(colorBackground)
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(title, 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(tview.NewFlex().
+			AddItem(nil, 0, 1, false).
+			AddItem(form, 50, 0, true).
+			AddItem(nil, 0, 1, false),
+			27, 0, true).
+		AddItem(helpView, 1, 0, false).
+		AddItem(nil, 0, 1, false)
+	flex.SetBackgroundColor(colorBackground)
+
+	return flex
+}
+
+// buildSettings shows a form for the config file's settings, passing the
+// edited config to onSave. Settings the form doesn't cover, like the menu,
+// are kept as they are.
+func buildSettings(app *tview.Application, pages *tview.Pages, conf Config, onSave func(Config)) *tview.Flex {
+	source := conf.Source
+	if source == "" {
+		source = "words"
+	}
+	language := (WordFilter{Language: conf.Language}).language()
+	caret := conf.Caret
+	if caret == "" {
+		caret = "underline"
+	}
+	count := func(n int) string {
+		if n <= 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+
+	form := tview.NewForm().
+		AddDropDown("Theme", append([]string{"auto"}, themeOrder...), optionIndex(themeOrder, conf.Theme), nil).
+		AddInputField("Start in", conf.Mode, 32, nil, nil).
+		AddDropDown("Source", sourceNames, slices.Index(sourceNames, source), nil).
+		AddDropDown("Language", languages, slices.Index(languages, language), nil).
+		AddCheckbox("Punctuation", conf.Punctuation, nil).
+		AddCheckbox("Numbers", conf.Numbers, nil).
+		AddDropDown("Layout", append([]string{"off"}, layoutOrder...), optionIndex(layoutOrder, conf.Layout), nil).
+		AddDropDown("OS layout", append([]string{"off"}, layoutOrder...), optionIndex(layoutOrder, conf.OSLayout), nil).
+		AddCheckbox("Keyboard", conf.Keyboard, nil).
+		AddCheckbox("Dead keys", conf.DeadKeys, nil).
+		AddDropDown("Stop on", append([]string{"off"}, stopOnModes...), optionIndex(stopOnModes, conf.StopOn), nil).
+		AddDropDown("Confidence", append([]string{"off"}, confidenceModes...), optionIndex(confidenceModes, conf.Confidence), nil).
+		AddCheckbox("Sudden death", conf.Fail.SuddenDeath, nil).
+		AddInputField("Fail below accuracy", optionalNumber(conf.Fail.MinAccuracy), 8, tview.InputFieldFloat, nil).
+		AddInputField("Fail below wpm", optionalNumber(conf.Fail.MinWPM), 8, tview.InputFieldFloat, nil).
+		AddCheckbox("Ignore accents", conf.IgnoreAccents, nil).
+		AddDropDown("Accent rules", append([]string{"off"}, matchLanguages...), optionIndex(matchLanguages, conf.Lang), nil).
+		AddCheckbox("Ignore case", conf.IgnoreCase, nil).
+		AddDropDown("Caret", caretStyles, slices.Index(caretStyles, caret), nil).
+		AddCheckbox("Caret blink", conf.CaretBlink, nil).
+		AddCheckbox("Highlight word", conf.HighlightWord, nil).
+		AddCheckbox("Tape", conf.Tape, nil).
+		AddInputField("Lines", count(conf.Lines), 8, tview.InputFieldInteger, nil).
+		AddCheckbox("Show typed", conf.ShowTyped, nil).
+		AddCheckbox("Blind", conf.Blind, nil).
+		AddInputField("Memory", count(conf.Memory), 8, tview.InputFieldInteger, nil).
+		AddCheckbox("Sound", conf.Sound, nil)
+	form.SetItemPadding(0)
+
+	modeField := form.GetFormItemByLabel("Start in").(*tview.InputField)
+	modeField.SetPlaceholder("menu, time 30, words 25, quote or zen")
+	modeField.SetPlaceholderTextColor(colorSubtle)
+
+	checked := func(label string) bool {
+		return form.GetFormItemByLabel(label).(*tview.Checkbox).IsChecked()
+	}
+	option := func(label string) string {
+		_, text := form.GetFormItemByLabel(label).(*tview.DropDown).GetCurrentOption()
+		return text
+	}
+	text := func(label string) string {
+		return form.GetFormItemByLabel(label).(*tview.InputField).GetText()
+	}
+
+	form.AddButton("Save", func() {
+		c := conf
+		c.Theme = offOption(form, "Theme")
+		c.Mode = strings.TrimSpace(modeField.GetText())
+		c.Source = option("Source")
+		if c.Source == "words" {
+			c.Source = ""
+		}
+		c.Language = option("Language")
+		if c.Language == "english" {
+			c.Language = ""
+		}
+		c.Punctuation = checked("Punctuation")
+		c.Numbers = checked("Numbers")
+		c.Layout = offOption(form, "Layout")
+		c.OSLayout = offOption(form, "OS layout")
+		c.Keyboard = checked("Keyboard")
+		c.DeadKeys = checked("Dead keys")
+		c.StopOn = offOption(form, "Stop on")
+		c.Confidence = offOption(form, "Confidence")
+		c.Fail.SuddenDeath = checked("Sudden death")
+		c.Fail.MinAccuracy, _ = strconv.ParseFloat(text("Fail below accuracy"), 64)
+		c.Fail.MinWPM, _ = strconv.ParseFloat(text("Fail below wpm"), 64)
+		c.Matching = Matching{
+			IgnoreAccents: checked("Ignore accents"),
+			IgnoreCase:    checked("Ignore case"),
+			Lang:          offOption(form, "Accent rules"),
+		}
+		c.Caret = option("Caret")
+		if c.Caret == "underline" {
+			c.Caret = ""
+		}
+		c.CaretBlink = checked("Caret blink")
+		c.HighlightWord = checked("Highlight word")
+		c.Tape = checked("Tape")
+		c.Lines, _ = strconv.Atoi(text("Lines"))
+		c.ShowTyped = checked("Show typed")
+		c.Blind = checked("Blind")
+		c.Memory, _ = strconv.Atoi(text("Memory"))
+		c.Sound = checked("Sound")
+		if err := c.Validate(); err != nil {
+			showMessage(pages, "Can't save settings", err.Error())
+			return
+		}
+		onSave(c)
+	})
+	form.AddButton("Cancel", func() {
+		pages.SwitchToPage("menu")
+	})
+	form.SetCancelFunc(func() {
+		pages.SwitchToPage("menu")
+	})
+
+	form.SetBackgroundColor(colorBackground)
+	form.SetLabelColor(colorAccent)
+	form.SetFieldBackgroundColor(blendColors(colorBackground, colorCorrect, 0.1))
+	form.SetFieldTextColor(colorCorrect)
+	form.SetButtonBackgroundColor(colorAccent)
+	form.SetButtonTextColor(colorBackground)
+
+	title := tview.NewTextView().
+		SetText("Settings").
+		SetTextAlign(tview.AlignCenter).
+		SetTextColor(colorAccent)
+	title.SetBackgroundColor(colorBackground)
+
+	helpView := tview.NewTextView().
+		SetText("Saved to " + configPath() + "\nCommand line flags and TERM_TYPE_ variables still win\n[tab] next field  [esc] back to menu").
+		SetTextAlign(tview.AlignCenter).
+		SetTextColor(colorSubtle)
+	helpView.SetBackgroundColor(colorBackground)
+
+	// The form takes the rows there are, scrolling to the focused field
+	// on short terminals
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 1, 0, false).
+		AddItem(title, 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(tview.NewFlex().
+			AddItem(nil, 0, 1, false).
+			AddItem(form, 56, 0, true).
+			AddItem(nil, 0, 1, false),
+			0, 1, true).
+		AddItem(helpView, 3, 0, false).
+		AddItem(nil, 1, 0, false)
+	flex.SetBackgroundColor(colorBackground)
+
+	return flex
+}
+
+// optionalNumber formats a threshold for an input field, leaving it blank
+// when it's unset.
+func optionalNumber(n float64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// optionIndex returns the position of value in a dropdown whose first
+// option is "off" followed by options.
+func optionIndex(options []string, value string) int {
+	for i, o := range options {
+		if o == value {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// offOption reads a dropdown built with optionIndex, returning "" for off.
+func offOption(form *tview.Form, label string) string {
+	idx, text := form.GetFormItemByLabel(label).(*tview.DropDown).GetCurrentOption()
+	if idx <= 0 {
+		return ""
+	}
+	return text
+}
+
+// showMessage displays a notice over the current page until dismissed.
+func showMessage(pages *tview.Pages, title string, text string) {
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"OK"}).
+		SetDoneFunc(func(int, string) {
+			pages.RemovePage("message")
+		})
+	modal.SetTitle(" " + title + " ")
+	modal.SetBackgroundColor(colorBackground)
+	modal.SetTextColor(colorCorrect)
+	modal.SetButtonBackgroundColor(colorAccent)
+	modal.SetButtonTextColor(colorBackground)
+	pages.AddPage("message", modal, true, true)
+}
@@ -2,29 +2,420 @@ package main
 
 import (
 	"embed"
+	"errors"
+	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
-//go:embed words.txt
+//go:embed words.txt words_*.txt quotes.txt
 var wordsFile embed.FS
 
-var wordList []string
+// wordLists holds each language's common words, most frequent first. The
+// English list is words.txt; the others are words_<language>.txt.
+var wordLists = make(map[string][]string)
+
+// commonWords is how much of a list tests without letter or length filters
+// pick from. The English list goes on past it so those filters still leave
+// words to type.
+const commonWords = 160
+
+var languages = []string{"english", "german", "french", "spanish"}
+
+// quoteList holds the passages used by quote tests, one per line of
+// quotes.txt.
+var quoteList []string
 
 func init() {
-	data, _ := wordsFile.ReadFile("words.txt")
+	wordLists["english"] = readLines("words.txt")
+	for _, lang := range languages[1:] {
+		wordLists[lang] = readLines("words_" + lang + ".txt")
+	}
+	quoteList = readLines("quotes.txt")
+}
+
+// readLines returns the non-blank lines of an embedded file.
+func readLines(name string) []string {
+	data, _ := wordsFile.ReadFile(name)
+	var lines []string
 	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
-		w := strings.TrimSpace(line)
-		if w != "" {
-			wordList = append(wordList, w)
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// sourceNames lists the word sources in the order the menu cycles them.
+var sourceNames = []string{"words", "markov"}
+
+func isSource(name string) bool {
+	for _, s := range sourceNames {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// WordFilter chooses the words the "words" source picks from: a language's
+// word list, narrowed by the other constraints. Zero values mean English
+// and no constraint.
+type WordFilter struct {
+	Language string `json:"language,omitempty"`
+
+	MinLen  int    `json:"min_len,omitempty"`
+	MaxLen  int    `json:"max_len,omitempty"`
+	Top     int    `json:"top,omitempty"`     // only the Top most frequent words
+	Only    string `json:"only,omitempty"`    // letters words may be made of
+	Exclude string `json:"exclude,omitempty"` // letters words may not contain
+	Require string `json:"require,omitempty"` // words must contain one of these
+
+	// Keys limits words to those typeable with a group of keys, such as
+	// "home+top", on Layout (qwerty if unset).
+	Layout string `json:"layout,omitempty"`
+	Keys   string `json:"keys,omitempty"`
+}
+
+func (f WordFilter) IsZero() bool {
+	return f == WordFilter{}
+}
+
+// narrows reports whether the filter limits words by their letters or
+// length, and so picks from the whole list.
+func (f WordFilter) narrows() bool {
+	return f.MinLen > 0 || f.MaxLen > 0 || f.Only != "" || f.Exclude != "" || f.Require != "" || f.Keys != ""
+}
+
+// keys returns the characters the Keys group allows, or "" when the filter
+// doesn't limit keys.
+func (f WordFilter) keys() (string, error) {
+	if f.Keys == "" {
+		return "", nil
+	}
+	layout := f.Layout
+	if layout == "" {
+		layout = "qwerty"
+	}
+	return layoutKeys(layout, f.Keys)
+}
+
+// match reports whether word passes every constraint except Top, which
+// depends on the word's rank rather than the word itself. keys is the
+// result of f.keys().
+func (f WordFilter) match(word string, keys string) bool {
+	n := len([]rune(word))
+	if f.MinLen > 0 && n < f.MinLen {
+		return false
+	}
+	if f.MaxLen > 0 && n > f.MaxLen {
+		return false
+	}
+	lower := strings.ToLower(word)
+	for _, r := range lower {
+		if f.Only != "" && !strings.ContainsRune(strings.ToLower(f.Only), r) {
+			return false
+		}
+		if strings.ContainsRune(strings.ToLower(f.Exclude), r) {
+			return false
+		}
+		if keys != "" && !strings.ContainsRune(keys, r) {
+			return false
+		}
+	}
+	if f.Require != "" && !strings.ContainsAny(lower, strings.ToLower(f.Require)) {
+		return false
+	}
+	return true
+}
+
+// language returns the word list's language, defaulting to English.
+func (f WordFilter) language() string {
+	if f.Language == "" {
+		return "english"
+	}
+	return f.Language
+}
+
+// Words returns the words of the language's list that pass the filter,
+// keeping the list's most-frequent-first order.
+func (f WordFilter) Words() []string {
+	keys, err := f.keys()
+	if err != nil {
+		return nil
+	}
+	words := wordLists[f.language()]
+	limit := f.Top
+	if limit == 0 && !f.narrows() {
+		limit = commonWords
+	}
+	if limit > 0 && limit < len(words) {
+		words = words[:limit]
+	}
+	var out []string
+	for _, w := range words {
+		if f.match(w, keys) {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// minPool is the fewest words a test picks from when its letters are
+// limited; made-up words from those letters make up the rest.
+const minPool = 30
+
+// Pool returns the words a test with the filter picks from: Words, topped
+// up with made-up words when the allowed letters leave fewer than minPool,
+// so a drill on a few keys doesn't repeat the same handful of words.
+func (f WordFilter) Pool(rng *rand.Rand) []string {
+	words := f.Words()
+	letters := f.letters()
+	if letters == "" || len(words) >= minPool {
+		return words
+	}
+	keys, _ := f.keys()
+	seen := make(map[string]bool)
+	for _, w := range words {
+		seen[w] = true
+	}
+	for tries := 0; len(words) < minPool && tries < minPool*50; tries++ {
+		w := madeUpWord(letters, f.MinLen, f.MaxLen, rng)
+		if !seen[w] && f.match(w, keys) {
+			seen[w] = true
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// letters returns the letters and digits the filter allows when it limits
+// them with Only or Keys, or "" when any letter will do.
+func (f WordFilter) letters() string {
+	if f.Only == "" && f.Keys == "" {
+		return ""
+	}
+	keys, err := f.keys()
+	if err != nil {
+		return ""
+	}
+	allowed := keys
+	if allowed == "" {
+		allowed = strings.ToLower(f.Only)
+	}
+	var b strings.Builder
+	for _, r := range allowed {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+		if f.Only != "" && !strings.ContainsRune(strings.ToLower(f.Only), r) {
+			continue
+		}
+		if strings.ContainsRune(strings.ToLower(f.Exclude), r) || strings.ContainsRune(b.String(), r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// madeUpWord strings letters together into a word between minLen and
+// maxLen long (2 to 6 when unset), alternating vowels and consonants when
+// letters has both so it can be read aloud.
+func madeUpWord(letters string, minLen, maxLen int, rng *rand.Rand) string {
+	var vowels, consonants []rune
+	for _, r := range letters {
+		if strings.ContainsRune("aeiouy", r) {
+			vowels = append(vowels, r)
+		} else {
+			consonants = append(consonants, r)
+		}
+	}
+	switch {
+	case maxLen == 0:
+		minLen = max(minLen, 2)
+		maxLen = max(minLen, 6)
+	case minLen < 2:
+		minLen = min(2, maxLen)
+	}
+	n := minLen + rng.Intn(maxLen-minLen+1)
+	all := []rune(letters)
+	vowel := rng.Intn(2) == 0
+	word := make([]rune, n)
+	for i := range word {
+		switch {
+		case len(vowels) == 0 || len(consonants) == 0:
+			word[i] = all[rng.Intn(len(all))]
+		case vowel:
+			word[i] = vowels[rng.Intn(len(vowels))]
+		default:
+			word[i] = consonants[rng.Intn(len(consonants))]
+		}
+		vowel = !vowel
+	}
+	return string(word)
+}
+
+// Validate reports filters that can't produce a test.
+func (f WordFilter) Validate() error {
+	if wordLists[f.language()] == nil {
+		return fmt.Errorf("unknown language %q (choose from %s)", f.Language, strings.Join(languages, ", "))
+	}
+	if f.MinLen > 0 && f.MaxLen > 0 && f.MinLen > f.MaxLen {
+		return errors.New("minimum word length is greater than the maximum")
+	}
+	if _, err := f.keys(); err != nil {
+		return err
+	}
+	if len(f.Pool(rand.New(rand.NewSource(0)))) == 0 {
+		return errors.New("no words match the word filters")
+	}
+	return nil
+}
+
+// String describes the active constraints, e.g. "3-6 letters, only asdf".
+func (f WordFilter) String() string {
+	var parts []string
+	if f.Language != "" {
+		parts = append(parts, f.Language)
+	}
+	switch {
+	case f.MinLen > 0 && f.MaxLen > 0:
+		parts = append(parts, fmt.Sprintf("%d-%d letters", f.MinLen, f.MaxLen))
+	case f.MinLen > 0:
+		parts = append(parts, fmt.Sprintf("%d+ letters", f.MinLen))
+	case f.MaxLen > 0:
+		parts = append(parts, fmt.Sprintf("up to %d letters", f.MaxLen))
+	}
+	if f.Top > 0 {
+		parts = append(parts, fmt.Sprintf("top %d", f.Top))
+	}
+	if f.Only != "" {
+		parts = append(parts, "only "+f.Only)
+	}
+	if f.Exclude != "" {
+		parts = append(parts, "no "+f.Exclude)
+	}
+	if f.Require != "" {
+		parts = append(parts, "with "+f.Require)
+	}
+	if f.Keys != "" {
+		layout := f.Layout
+		if layout == "" {
+			layout = "qwerty"
 		}
+		parts = append(parts, layout+" "+f.Keys)
 	}
+	return strings.Join(parts, ", ")
 }
 
-func pickWords(n int) string {
+// textGenerator produces the text of a generated test a batch of words at
+// a time, so a timed test can keep extending it for as long as it runs.
+// The same config and seed always produce the same words, however they're
+// batched.
+type textGenerator struct {
+	rng    *rand.Rand
+	pool   []string     // words to pick from, for the words source
+	markov *MarkovChain // for the markov source
+	prose  markovProse  // where the markov prose left off
+
+	// Dressing for picked words
+	punctuation, numbers bool
+	sentenceDone         bool // the last word ended a sentence
+}
+
+func newTextGenerator(cfg TestConfig, seed int64) (*textGenerator, error) {
+	g := &textGenerator{
+		rng:          rand.New(rand.NewSource(seed)),
+		punctuation:  cfg.Punctuation,
+		numbers:      cfg.Numbers,
+		sentenceDone: true,
+	}
+	if cfg.Quote || cfg.Zen {
+		return g, nil
+	}
+	if cfg.Source == "markov" {
+		g.markov = getMarkovModel()
+		return g, nil
+	}
+	if err := cfg.Filter.Validate(); err != nil {
+		return nil, err
+	}
+	g.pool = cfg.Filter.Pool(g.rng)
+	return g, nil
+}
+
+// Next returns the next n words of the text.
+func (g *textGenerator) Next(n int) string {
+	if g.markov != nil {
+		return strings.Join(g.markov.generate(&g.prose, n, g.rng), " ")
+	}
+	if !g.punctuation && !g.numbers {
+		return pickWords(g.pool, n, g.rng)
+	}
 	words := make([]string, n)
 	for i := range words {
-		words[i] = wordList[rand.Intn(len(wordList))]
+		w := g.pool[g.rng.Intn(len(g.pool))]
+		if g.numbers && g.rng.Intn(8) == 0 {
+			w = strconv.Itoa(g.rng.Intn(10000))
+		}
+		if g.punctuation {
+			w = g.punctuate(w)
+		}
+		words[i] = w
 	}
 	return strings.Join(words, " ")
 }
+
+// punctuate makes a picked word part of a sentence: capitalized after the
+// end of the last one, and sometimes followed by punctuation.
+func (g *textGenerator) punctuate(w string) string {
+	if g.sentenceDone {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		w = string(r)
+	}
+	g.sentenceDone = false
+	switch n := g.rng.Intn(100); {
+	case n < 8:
+		w += "."
+		g.sentenceDone = true
+	case n < 10:
+		w += "?"
+		g.sentenceDone = true
+	case n < 11:
+		w += "!"
+		g.sentenceDone = true
+	case n < 20:
+		w += ","
+	case n < 22:
+		w += ";"
+	case n < 24:
+		w = `"` + w + `"`
+	case n < 25:
+		w = "(" + w + ")"
+	}
+	return w
+}
+
+// Quote returns one of the quotes, at random.
+func (g *textGenerator) Quote() string {
+	return quoteList[g.rng.Intn(len(quoteList))]
+}
+
+// pickWords returns n words drawn at random from pool.
+func pickWords(pool []string, n int, rng *rand.Rand) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = pool[rng.Intn(len(pool))]
+	}
+	return strings.Join(words, " ")
+}
+
+// newSeed returns a fresh seed for a test that wasn't given one. Seeds are
+// kept short so they're easy to read off the results screen and share.
+func newSeed() int64 {
+	return rand.Int63n(1_000_000_000)
+}